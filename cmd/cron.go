@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression: minute hour day-of-month month day-of-week
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Day matching follows cron: if both day fields are restricted either may match
+	domRestricted, dowRestricted bool
+}
+
+type cronField struct {
+	min, max int
+}
+
+var cronFields = []cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0 and 7 are both Sunday
+}
+
+// Parse a standard five-field cron expression supporting *, lists, ranges, and steps
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var bits [5]uint64
+	for idx, field := range fields {
+		parsed, err := parseCronField(field, cronFields[idx])
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %v", field, err)
+		}
+		bits[idx] = parsed
+	}
+
+	// Fold Sunday-as-7 into 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &cronSchedule{
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}, nil
+}
+
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			parsed, err := strconv.Atoi(after)
+			if err != nil || parsed <= 0 {
+				return 0, fmt.Errorf("invalid step %q", after)
+			}
+			rangePart, step = before, parsed
+		}
+
+		low, high := bounds.min, bounds.max
+		if rangePart != "*" {
+			lowString, highString, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(lowString); err != nil {
+				return 0, fmt.Errorf("invalid value %q", lowString)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highString); err != nil {
+					return 0, fmt.Errorf("invalid value %q", highString)
+				}
+			} else if step > 1 {
+				// a/n means every n starting at a
+				high = bounds.max
+			}
+		}
+
+		if low < bounds.min || high > bounds.max || low > high {
+			return 0, fmt.Errorf("%q out of range %d-%d", rangePart, bounds.min, bounds.max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c *cronSchedule) matchesDay(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next returns the first matching minute strictly after t, or the zero time if none exists within five years.
+// Wall-clock times skipped by a daylight saving jump never fire, and times repeated when the clocks go back
+// fire only on their first occurrence.
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = cronAdvance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = cronAdvance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			// Step in absolute time, since the next wall-clock hour may not exist on a spring-forward day
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		if earlier := t.Add(-time.Hour); earlier.Hour() == t.Hour() && earlier.Day() == t.Day() {
+			// Second pass through an hour the clocks went back over
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Move to the wall-clock time next, falling back to the next hour when a daylight saving
+// gap normalizes next to a time that isn't after t
+func cronAdvance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "* * * * *"},
+		{spec: "0 19 * * 5"},
+		{spec: "*/15 0-6,22-23 1,15 1-12/2 7"},
+		{spec: "5/10 * * * *"},
+		{spec: "0 0 31 2 *"},
+		{spec: "* * * *", wantErr: true},
+		{spec: "* * * * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "*/x * * * *", wantErr: true},
+		{spec: "a * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := parseCron(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCron(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, newYork)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "next minute",
+			spec: "* * * * *",
+			from: at(2026, time.January, 1, 12, 0),
+			want: at(2026, time.January, 1, 12, 1),
+		},
+		{
+			name: "strictly after",
+			spec: "0 19 * * *",
+			from: at(2026, time.January, 1, 19, 0),
+			want: at(2026, time.January, 2, 19, 0),
+		},
+		{
+			name: "step from start value",
+			spec: "5/20 * * * *",
+			from: at(2026, time.January, 1, 12, 6),
+			want: at(2026, time.January, 1, 12, 25),
+		},
+		{
+			name: "step over range",
+			spec: "0 8-18/5 * * *",
+			from: at(2026, time.January, 1, 13, 30),
+			want: at(2026, time.January, 1, 18, 0),
+		},
+		{
+			name: "day of week only",
+			spec: "0 19 * * 5",
+			from: at(2026, time.January, 1, 0, 0),
+			want: at(2026, time.January, 2, 19, 0),
+		},
+		{
+			name: "sunday as 7",
+			spec: "0 12 * * 7",
+			from: at(2026, time.January, 1, 0, 0),
+			want: at(2026, time.January, 4, 12, 0),
+		},
+		{
+			name: "day of month or day of week",
+			spec: "0 0 15 * 1",
+			from: at(2026, time.January, 1, 0, 0),
+			want: at(2026, time.January, 5, 0, 0),
+		},
+		{
+			name: "day of month or day of week matches month day first",
+			spec: "0 0 15 * 1",
+			from: at(2026, time.January, 13, 0, 0),
+			want: at(2026, time.January, 15, 0, 0),
+		},
+		{
+			name: "day of month with wildcard day of week",
+			spec: "0 0 15 * *",
+			from: at(2026, time.January, 1, 0, 0),
+			want: at(2026, time.January, 15, 0, 0),
+		},
+		{
+			name: "month rollover",
+			spec: "0 0 1 3 *",
+			from: at(2026, time.March, 1, 0, 0),
+			want: at(2027, time.March, 1, 0, 0),
+		},
+		{
+			name: "leap day",
+			spec: "0 0 29 2 *",
+			from: at(2026, time.January, 1, 0, 0),
+			want: at(2028, time.February, 29, 0, 0),
+		},
+		{
+			name: "impossible date",
+			spec: "0 0 31 2 *",
+			from: at(2026, time.January, 1, 0, 0),
+			want: time.Time{},
+		},
+		{
+			name: "spring forward skips the missing hour",
+			spec: "30 2 * * *",
+			from: at(2026, time.March, 7, 3, 0),
+			want: at(2026, time.March, 9, 2, 30),
+		},
+		{
+			name: "spring forward weekly",
+			spec: "0 2 * * 0",
+			from: at(2026, time.March, 7, 0, 0),
+			want: at(2026, time.March, 15, 2, 0),
+		},
+		{
+			name: "spring forward hourly",
+			spec: "0 * * * *",
+			from: at(2026, time.March, 8, 1, 30),
+			want: at(2026, time.March, 8, 3, 0),
+		},
+		{
+			name: "fall back fires once",
+			spec: "30 1 * * *",
+			from: at(2026, time.November, 1, 1, 30),
+			want: at(2026, time.November, 2, 1, 30),
+		},
+		{
+			name: "fall back fires before the repeat",
+			spec: "30 1 * * *",
+			from: at(2026, time.November, 1, 0, 0),
+			want: at(2026, time.November, 1, 1, 30),
+		},
+		{
+			name: "fall back hourly",
+			spec: "0 * * * *",
+			from: at(2026, time.November, 1, 1, 0),
+			want: at(2026, time.November, 1, 2, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCron(%q): %v", tt.spec, err)
+			}
+			got := schedule.Next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
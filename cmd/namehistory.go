@@ -35,7 +35,7 @@ func NameHistoryHandler(s *discordgo.Session, m *discordgo.GuildMemberUpdate, co
 	})
 
 	if err != nil {
-		log.Printf("Failed to write name change to the DB %w", err)
+		log.Printf("Failed to write name change to the DB %v", err)
 	}
 
 	message := fmt.Sprintf("<@%s> has a new name!", m.User.ID)
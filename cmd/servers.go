@@ -2,48 +2,91 @@ package cmd
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/clbx/juicebot/util"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
+	"k8s.io/client-go/util/retry"
 )
 
-var k8sClient *kubernetes.Clientset
+// Check if guildID is in the server's comma-separated guilds annotation
+func isGuildAuthorized(server *gameServer, guildID string) bool {
+	return slices.Contains(server.Guilds(), guildID)
+}
 
-// Check if guildID is in comma-separated list of guild IDs from annotations
-func isGuildAuthorized(annotations map[string]string, guildID string) bool {
-	annotationValue, exists := annotations["juicecloud.org/juicebot-guilds"]
-	if !exists {
-		return false
+// Namespaces a guild may use, falling back to the global list
+func guildNamespaces(config *util.JuiceBotConfig, guildID string) []string {
+	for _, guildNamespaces := range config.Servers.GuildNamespaces {
+		if guildNamespaces.GuildID == guildID {
+			return guildNamespaces.Namespaces
+		}
 	}
+	return config.Servers.Namespaces
+}
 
-	guilds := strings.Split(annotationValue, ",")
-	for _, guild := range guilds {
-		if strings.TrimSpace(guild) == guildID {
-			return true
+// Every namespace any guild may use, which is where discovery looks
+func allNamespaces(config *util.JuiceBotConfig) []string {
+	namespaces := slices.Clone(config.Servers.Namespaces)
+	for _, guildNamespaces := range config.Servers.GuildNamespaces {
+		for _, namespace := range guildNamespaces.Namespaces {
+			if !slices.Contains(namespaces, namespace) {
+				namespaces = append(namespaces, namespace)
+			}
 		}
 	}
-	return false
+	return namespaces
 }
 
-// Initialize Kubernetes client (kubeconfig first, then in-cluster)
-func initKubernetesClient() error {
+// Initialize the cluster's clients from its kubeconfig and context, or in-cluster config.
+// Clusters not named in config try ~/.kube/config first, then in-cluster.
+func (b *kubernetesBackend) initClient() error {
 	var config *rest.Config
 	var err error
 
-	// Try kubeconfig first
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig := filepath.Join(home, ".kube", "config")
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	switch {
+	case b.InCluster:
+		config, err = rest.InClusterConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create in-cluster kubernetes config: %v", err)
+		}
+	case b.Kubeconfig != "":
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: b.Kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: b.Context},
+		).ClientConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load kubeconfig %s: %v", b.Kubeconfig, err)
+		}
+	case homedir.HomeDir() != "":
+		// Try kubeconfig first
+		kubeconfig := filepath.Join(homedir.HomeDir(), ".kube", "config")
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: b.Context},
+		).ClientConfig()
 		if err != nil {
 			// Fall back to in-cluster config
 			config, err = rest.InClusterConfig()
@@ -51,7 +94,7 @@ func initKubernetesClient() error {
 				return fmt.Errorf("failed to create kubernetes config: %v", err)
 			}
 		}
-	} else {
+	default:
 		// Try in-cluster config directly
 		config, err = rest.InClusterConfig()
 		if err != nil {
@@ -59,14 +102,392 @@ func initKubernetesClient() error {
 		}
 	}
 
-	k8sClient, err = kubernetes.NewForConfig(config)
+	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return fmt.Errorf("failed to create kubernetes client: %v", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic kubernetes client: %v", err)
+	}
+
+	b.client, b.dynamic = client, dynamicClient
 	return nil
 }
 
+var (
+	errServerIDFormat = errors.New("server ID must be in format: namespace/name or cluster/namespace/name")
+	errServerNotFound = errors.New("server not found")
+	// Only Deployments and StatefulSets have a pod template to bump
+	errRestartUnsupported = errors.New("restart is not supported for this kind")
+)
+
+// gameServer is the common view of a labelled Deployment, StatefulSet, or custom scalable resource
+type gameServer struct {
+	Kind string
+	// Only set for custom resources, which are managed through the dynamic client
+	Resource      schema.GroupVersionResource
+	Namespace     string
+	Name          string
+	DisplayName   string
+	Labels        map[string]string
+	Annotations   map[string]string
+	Selector      *metav1.LabelSelector
+	PodLabels     map[string]string
+	Replicas      int32
+	ReadyReplicas int32
+	// Resource requests of a single pod, from the pod template
+	PodRequests corev1.ResourceList
+
+	annotationPrefix string
+	backend          GameServerBackend
+}
+
+// Resolve a namespace/name server ID to the workload behind it,
+// applying the same label and guild checks as start/stop
+func (b *kubernetesBackend) Get(config *util.JuiceBotConfig, serverID string, guildID string, userID string) (*gameServer, error) {
+	if !b.servesGuild(guildID) {
+		return nil, errServerNotFound
+	}
+
+	parts := strings.Split(serverID, "/")
+	switch {
+	case len(parts) != 2 && len(parts) != 3:
+		return nil, errServerIDFormat
+	// Named clusters qualify IDs with the cluster name, other IDs may belong to another backend
+	case b.Name == "" && len(parts) == 3, b.Name != "" && (len(parts) == 2 || parts[0] != b.Name):
+		return nil, errServerNotFound
+	}
+
+	namespace, name := parts[len(parts)-2], parts[len(parts)-1]
+
+	// Only allow operations in namespaces configured for this guild
+	if !slices.Contains(guildNamespaces(config, guildID), namespace) {
+		return nil, errServerNotFound
+	}
+
+	// Connect only once the ID is known to be ours, so an unreachable cluster doesn't block the others
+	if err := b.connect(); err != nil {
+		return nil, err
+	}
+
+	var server *gameServer
+
+	deployment, err := b.client.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err == nil {
+		server = gameServerFromDeployment(config, b, deployment)
+	} else if !k8serrors.IsNotFound(err) {
+		return nil, err
+	} else {
+		statefulSet, err := b.client.AppsV1().StatefulSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err == nil {
+			server = gameServerFromStatefulSet(config, b, statefulSet)
+		} else if !k8serrors.IsNotFound(err) {
+			return nil, err
+		} else {
+			// Fall back to the custom resources from config
+			server, err = findCustomGameServer(config, b, namespace, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Check if it matches the game server selector and belongs to this guild
+	selector, err := labels.Parse(config.Servers.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector %q: %v", config.Servers.LabelSelector, err)
+	}
+	if !selector.Matches(labels.Set(server.Labels)) {
+		log.Printf("User %s in guild %s attempted to access %s %s/%s without juicebot label", userID, guildID, strings.ToLower(server.Kind), namespace, name)
+		return nil, errServerNotFound
+	}
+	if !isGuildAuthorized(server, guildID) {
+		annotationValue, _ := server.Annotation("guilds")
+		log.Printf("User %s in guild %s attempted to access resource %s/%s belonging to guilds %s", userID, guildID, namespace, name, annotationValue)
+		return nil, errServerNotFound
+	}
+
+	return server, nil
+}
+
+// List every labelled game server that belongs to a guild
+func (b *kubernetesBackend) List(config *util.JuiceBotConfig, guildID string) ([]*gameServer, error) {
+	if !b.servesGuild(guildID) {
+		return nil, nil
+	}
+	all, err := b.listAll(config)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := guildNamespaces(config, guildID)
+	var servers []*gameServer
+	for _, server := range all {
+		if slices.Contains(namespaces, server.Namespace) && isGuildAuthorized(server, guildID) {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+// List every labelled game server in every cluster regardless of guild. Servers from
+// clusters that worked are still returned alongside the errors of those that didn't.
+func listAllGameServers(config *util.JuiceBotConfig) ([]*gameServer, error) {
+	var servers []*gameServer
+	var errs []error
+	for _, cluster := range kubernetesClusters(config) {
+		clusterServers, err := cluster.listAll(config)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		servers = append(servers, clusterServers...)
+	}
+	return servers, errors.Join(errs...)
+}
+
+// List every labelled game server in the cluster regardless of guild
+func (b *kubernetesBackend) listAll(config *util.JuiceBotConfig) ([]*gameServer, error) {
+	if err := b.connect(); err != nil {
+		return nil, err
+	}
+
+	var servers []*gameServer
+	for _, namespace := range allNamespaces(config) {
+		deployments, err := b.client.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: config.Servers.LabelSelector,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deployments in %s: %v", b.describeNamespace(namespace), err)
+		}
+
+		statefulSets, err := b.client.AppsV1().StatefulSets(namespace).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: config.Servers.LabelSelector,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list statefulsets in %s: %v", b.describeNamespace(namespace), err)
+		}
+
+		for idx := range deployments.Items {
+			servers = append(servers, gameServerFromDeployment(config, b, &deployments.Items[idx]))
+		}
+		for idx := range statefulSets.Items {
+			servers = append(servers, gameServerFromStatefulSet(config, b, &statefulSets.Items[idx]))
+		}
+
+		custom, err := listCustomGameServers(config, b, namespace)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, custom...)
+	}
+	return servers, nil
+}
+
+func gameServerFromDeployment(config *util.JuiceBotConfig, cluster *kubernetesBackend, deployment *appsv1.Deployment) *gameServer {
+	server := &gameServer{
+		Kind:             "Deployment",
+		Namespace:        deployment.Namespace,
+		Name:             deployment.Name,
+		Labels:           deployment.Labels,
+		Annotations:      deployment.Annotations,
+		Selector:         deployment.Spec.Selector,
+		PodLabels:        deployment.Spec.Template.Labels,
+		PodRequests:      podSpecRequests(deployment.Spec.Template.Spec),
+		ReadyReplicas:    deployment.Status.ReadyReplicas,
+		annotationPrefix: config.Servers.AnnotationPrefix,
+		backend:          cluster,
+	}
+	// The API server defaults unset replicas to 1
+	server.Replicas = 1
+	if deployment.Spec.Replicas != nil {
+		server.Replicas = *deployment.Spec.Replicas
+	}
+	server.setDisplayName(config)
+	return server
+}
+
+func gameServerFromStatefulSet(config *util.JuiceBotConfig, cluster *kubernetesBackend, statefulSet *appsv1.StatefulSet) *gameServer {
+	server := &gameServer{
+		Kind:             "StatefulSet",
+		Namespace:        statefulSet.Namespace,
+		Name:             statefulSet.Name,
+		Labels:           statefulSet.Labels,
+		Annotations:      statefulSet.Annotations,
+		Selector:         statefulSet.Spec.Selector,
+		PodLabels:        statefulSet.Spec.Template.Labels,
+		PodRequests:      podSpecRequests(statefulSet.Spec.Template.Spec),
+		ReadyReplicas:    statefulSet.Status.ReadyReplicas,
+		annotationPrefix: config.Servers.AnnotationPrefix,
+		backend:          cluster,
+	}
+	// The API server defaults unset replicas to 1
+	server.Replicas = 1
+	if statefulSet.Spec.Replicas != nil {
+		server.Replicas = *statefulSet.Spec.Replicas
+	}
+	server.setDisplayName(config)
+	return server
+}
+
+func (g *gameServer) setDisplayName(config *util.JuiceBotConfig) {
+	g.DisplayName = g.Name
+	if displayName, ok := g.Labels[config.Servers.DisplayNameLabel]; ok {
+		g.DisplayName = displayName
+	}
+}
+
+// AnnotationKey is the full key of a juicebot annotation, e.g. "guilds" becomes juicecloud.org/juicebot-guilds
+func (g *gameServer) AnnotationKey(name string) string {
+	return g.annotationPrefix + name
+}
+
+// Annotation looks up a juicebot annotation by its short name
+func (g *gameServer) Annotation(name string) (string, bool) {
+	value, ok := g.Annotations[g.AnnotationKey(name)]
+	return value, ok
+}
+
+// Guilds parses the comma-separated list of guild IDs from the guilds annotation
+func (g *gameServer) Guilds() []string {
+	annotationValue, exists := g.Annotation("guilds")
+	if !exists {
+		return nil
+	}
+
+	var guilds []string
+	for _, guild := range strings.Split(annotationValue, ",") {
+		if guild = strings.TrimSpace(guild); guild != "" {
+			guilds = append(guilds, guild)
+		}
+	}
+	return guilds
+}
+
+// Set the replica count of the workload behind a server through its scale subresource.
+// Conflicts with controllers or other users updating the object are retried.
+func scaleGameServer(server *gameServer, replicas int32) error {
+	switch server.Kind {
+	case "Deployment":
+		return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			scale, err := server.cluster().client.AppsV1().Deployments(server.Namespace).GetScale(context.TODO(), server.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if scale.Spec.Replicas == replicas {
+				return nil
+			}
+			scale.Spec.Replicas = replicas
+			// Expected before the update so the watcher can't see it first
+			recordExpectedScale(server.ID(), replicas)
+			_, err = server.cluster().client.AppsV1().Deployments(server.Namespace).UpdateScale(context.TODO(), server.Name, scale, metav1.UpdateOptions{})
+			if err != nil {
+				clearExpectedScale(server.ID())
+			}
+			return err
+		})
+	case "StatefulSet":
+		return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			scale, err := server.cluster().client.AppsV1().StatefulSets(server.Namespace).GetScale(context.TODO(), server.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if scale.Spec.Replicas == replicas {
+				return nil
+			}
+			scale.Spec.Replicas = replicas
+			// Expected before the update so the watcher can't see it first
+			recordExpectedScale(server.ID(), replicas)
+			_, err = server.cluster().client.AppsV1().StatefulSets(server.Namespace).UpdateScale(context.TODO(), server.Name, scale, metav1.UpdateOptions{})
+			if err != nil {
+				clearExpectedScale(server.ID())
+			}
+			return err
+		})
+	}
+	if !server.Resource.Empty() {
+		return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			return scaleCustomGameServer(server, replicas)
+		})
+	}
+	return fmt.Errorf("unsupported kind %s", server.Kind)
+}
+
+// Set or, with a nil value, remove a juicebot annotation on the Deployment or StatefulSet behind a server
+func annotateGameServer(server *gameServer, name string, value *string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]*string{server.AnnotationKey(name): value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	switch server.Kind {
+	case "Deployment":
+		_, err = server.cluster().client.AppsV1().Deployments(server.Namespace).Patch(context.TODO(), server.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	case "StatefulSet":
+		_, err = server.cluster().client.AppsV1().StatefulSets(server.Namespace).Patch(context.TODO(), server.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	}
+	if !server.Resource.Empty() {
+		_, err = server.cluster().dynamic.Resource(server.Resource).Namespace(server.Namespace).Patch(context.TODO(), server.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	}
+	return fmt.Errorf("unsupported kind %s", server.Kind)
+}
+
+// Trigger a rolling restart the same way kubectl rollout restart does, by bumping a pod template annotation
+func restartGameServer(server *gameServer) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						"kubectl.kubernetes.io/restartedAt": time.Now().Format(time.RFC3339),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	switch server.Kind {
+	case "Deployment":
+		_, err = server.cluster().client.AppsV1().Deployments(server.Namespace).Patch(context.TODO(), server.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		return err
+	case "StatefulSet":
+		_, err = server.cluster().client.AppsV1().StatefulSets(server.Namespace).Patch(context.TODO(), server.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		return err
+	}
+	return errRestartUnsupported
+}
+
+// ID returns the namespace/name form users pass to /servers, prefixed with the cluster name for named clusters
+func (g *gameServer) ID() string {
+	if cluster := g.cluster(); cluster != nil && cluster.Name != "" {
+		return cluster.Name + "/" + g.Namespace + "/" + g.Name
+	}
+	return g.Namespace + "/" + g.Name
+}
+
+// State is "stopped" when scaled to zero, "starting" until a replica is ready, then "running"
+func (g *gameServer) State() string {
+	if g.ReadyReplicas > 0 {
+		return "running"
+	}
+	if g.Replicas > 0 {
+		return "starting"
+	}
+	return "stopped"
+}
+
 var ServersCommand = &discordgo.ApplicationCommand{
 	Name:        "servers",
 	Description: "Manage game servers",
@@ -75,6 +496,13 @@ var ServersCommand = &discordgo.ApplicationCommand{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
 			Name:        "list",
 			Description: "List all game servers",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "usage",
+					Description: "Show CPU and memory usage of running servers",
+				},
+			},
 		},
 		{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
@@ -82,10 +510,11 @@ var ServersCommand = &discordgo.ApplicationCommand{
 			Description: "Start a game server",
 			Options: []*discordgo.ApplicationCommandOption{
 				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "server",
-					Description: "Server ID to start",
-					Required:    true,
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Server ID to start",
+					Required:     true,
+					Autocomplete: true,
 				},
 			},
 		},
@@ -94,25 +523,281 @@ var ServersCommand = &discordgo.ApplicationCommand{
 			Name:        "stop",
 			Description: "Stop a game server",
 			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Server ID to stop",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "restart",
+			Description: "Restart a running game server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Server ID to restart",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "logs",
+			Description: "Fetch recent logs from a game server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Server ID to fetch logs from",
+					Required:     true,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "lines",
+					Description: "Number of lines to fetch (default 200)",
+					MinValue:    &logsMinValue,
+					MaxValue:    logsMaxLines,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "minutes",
+					Description: "Only fetch logs from the last N minutes",
+					MinValue:    &logsMinValue,
+					MaxValue:    logsMaxMinutes,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "schedule",
+			Description: "Show or set the start/stop schedule of a game server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Server ID to schedule",
+					Required:     true,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "schedule",
+					Description: "e.g. \"start 0 19 * * 5; stop 0 2 * * 6\", or \"off\" to clear",
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "players",
+			Description: "Show who is online on a game server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Server ID to query",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "usage",
+			Description: "Show how long game servers have been running",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "days",
+					Description: "Period to report on (default last week)",
+					Choices:     usageDayChoices,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "history",
+			Description: "Show recent actions on game servers",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Only show actions on this server",
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "backup",
+			Description: "Back up a game server's world",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Server ID to back up",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "backups",
+			Description: "List past backups of game servers",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Only show backups of this server",
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "rcon",
+			Description: "Run a console command on a game server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Server ID to run the command on",
+					Required:     true,
+					Autocomplete: true,
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "server",
-					Description: "Server ID to stop",
+					Name:        "command",
+					Description: "Command to run, e.g. save-all",
 					Required:    true,
 				},
 			},
 		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name:        "config",
+			Description: "View and change game server settings",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "get",
+					Description: "Show a game server's editable settings",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "server",
+							Description:  "Server ID to show settings for",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "key",
+							Description:  "Only show this setting",
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "set",
+					Description: "Change a game server setting",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "server",
+							Description:  "Server ID to change",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "key",
+							Description:  "Setting to change",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "value",
+							Description: "New value",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "reset",
+					Description: "Put a game server setting back to how it was before it was changed",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "server",
+							Description:  "Server ID to change",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "key",
+							Description:  "Setting to reset",
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "panel",
+			Description: "Post buttons to start, stop and restart game servers",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Only show this server",
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "status",
+			Description: "Show detailed status of a game server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "server",
+					Description:  "Server ID to inspect",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
 	},
 }
 
-func ServersAction(s *discordgo.Session, i *discordgo.InteractionCreate, config *util.JuiceBotConfig) {
+func ServersAction(s *discordgo.Session, i *discordgo.InteractionCreate, config *util.JuiceBotConfig, db *sql.DB) {
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		handleServersAutocomplete(s, i, config)
+		return
+	}
+
 	options := i.ApplicationCommandData().Options
 
 	if len(options) == 0 {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
-				Content: "Please specify a subcommand: list, start, or stop",
+				Content: "Please specify a subcommand: list, start, stop, restart, logs, schedule, players, usage, history, backup, backups, rcon, config, panel, or status",
 			},
 		})
 		return
@@ -122,138 +807,199 @@ func ServersAction(s *discordgo.Session, i *discordgo.InteractionCreate, config
 
 	switch subcommand.Name {
 	case "list":
-		handleListServers(s, i)
+		handleListServers(s, i, subcommand.Options, config)
 	case "start":
-		handleStartServer(s, i, subcommand.Options)
+		handleStartServer(s, i, subcommand.Options, config, db)
 	case "stop":
-		handleStopServer(s, i, subcommand.Options)
+		handleStopServer(s, i, subcommand.Options, config, db)
+	case "status":
+		handleServerStatus(s, i, subcommand.Options, config)
+	case "restart":
+		handleRestartServer(s, i, subcommand.Options, config, db)
+	case "logs":
+		handleServerLogs(s, i, subcommand.Options, config)
+	case "schedule":
+		handleServerSchedule(s, i, subcommand.Options, config, db)
+	case "players":
+		handleServerPlayers(s, i, subcommand.Options, config)
+	case "usage":
+		handleServerUsage(s, i, subcommand.Options, config, db)
+	case "history":
+		handleServerHistory(s, i, subcommand.Options, config, db)
+	case "backup":
+		handleServerBackup(s, i, subcommand.Options, config, db)
+	case "backups":
+		handleServerBackups(s, i, subcommand.Options, config, db)
+	case "rcon":
+		handleServerRcon(s, i, subcommand.Options, config, db)
+	case "config":
+		handleServerConfig(s, i, subcommand.Options, config, db)
+	case "panel":
+		handleServerPanel(s, i, subcommand.Options, config)
 	}
 }
 
-func handleListServers(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if k8sClient == nil {
-		if err := initKubernetesClient(); err != nil {
-			log.Printf("Failed to initialize Kubernetes client for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "❌ Unable to connect to game servers",
-				},
-			})
-			return
+// Discord's limit on the length of a component custom ID
+const customIDMaxLength = 100
+
+// Route button presses on /servers messages, custom IDs look like servers:<action>:<server id>
+func ServersComponentAction(s *discordgo.Session, i *discordgo.InteractionCreate, config *util.JuiceBotConfig, db *sql.DB) {
+	parts := strings.SplitN(i.MessageComponentData().CustomID, ":", 3)
+	if len(parts) != 3 {
+		return
+	}
+
+	switch parts[1] {
+	case "keepalive":
+		handleKeepAliveButton(s, i, resolveButtonServerID(config, i.GuildID, parts[2]), config, db)
+	case "history":
+		handleHistoryButton(s, i, parts[2], config, db)
+	case "configrestart":
+		handleConfigRestartButton(s, i, resolveButtonServerID(config, i.GuildID, parts[2]), config, db)
+	case "quotastop":
+		handleQuotaStopButton(s, i, resolveButtonServerID(config, i.GuildID, parts[2]), config, db)
+	case "panel":
+		handlePanelButton(s, i, parts[2], config, db)
+	case "vote":
+		// Anyone who can see the server may vote, the roles check applied when the vote was opened
+		handleStartVoteButton(s, i, resolveButtonServerID(config, i.GuildID, parts[2]), config, db)
+	}
+}
+
+// Build a button custom ID ending in a server ID. Discord rejects custom IDs over
+// 100 characters, so long server IDs are swapped for a hash of the ID.
+func serverCustomID(prefix string, serverID string) string {
+	if len(prefix)+len(serverID) <= customIDMaxLength {
+		return prefix + serverID
+	}
+	return prefix + "#" + hashServerID(serverID)
+}
+
+func hashServerID(serverID string) string {
+	sum := sha256.Sum256([]byte(serverID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Map a hashed server ID from serverCustomID back to the ID by checking the guild's servers.
+// Anything else, or a hash no server matches, is returned as it was.
+func resolveButtonServerID(config *util.JuiceBotConfig, guildID string, value string) string {
+	hash, ok := strings.CutPrefix(value, "#")
+	if !ok {
+		return value
+	}
+	// Servers from clusters that answered can still match
+	servers, err := listGameServers(config, guildID)
+	if err != nil {
+		log.Printf("Failed to list game servers to resolve button %s in guild %s: %v", value, guildID, err)
+	}
+	for _, server := range servers {
+		if hashServerID(server.ID()) == hash {
+			return server.ID()
 		}
 	}
+	return value
+}
+
+func handleListServers(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig) {
+	showUsage := false
+	for _, opt := range options {
+		if opt.Name == "usage" {
+			showUsage = opt.BoolValue()
+		}
+	}
+
+	// Player queries can take a couple of seconds, so defer the response
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
 
 	var content string = "**Game Servers:**\n"
 	guildID := i.GuildID
 
-	// List all resources with the label in the games namespace, then filter by guild ID via annotations
-	deployments, err := k8sClient.AppsV1().Deployments("games").List(context.TODO(), metav1.ListOptions{
-		LabelSelector: "juicecloud.org/juicebot-game-server=true",
-	})
+	// Services are looked up once per namespace for connect addresses
+	services := map[string][]corev1.Service{}
+
+	// List the guild's servers from every backend, filtered by guild ID
+	servers, err := listGameServers(config, guildID)
 	if err != nil {
-		log.Printf("Failed to list deployments for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "❌ Unable to retrieve game servers",
-			},
+		log.Printf("Failed to list game servers for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
+	}
+	if err != nil && len(servers) == 0 {
+		content := "❌ Unable to retrieve game servers"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
 		})
 		return
 	}
 
-	// List StatefulSets
-	statefulSets, err := k8sClient.AppsV1().StatefulSets("games").List(context.TODO(), metav1.ListOptions{
-		LabelSelector: "juicecloud.org/juicebot-game-server=true",
-	})
-	if err != nil {
-		log.Printf("Failed to list statefulsets for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "❌ Unable to retrieve game servers",
-			},
+	if len(servers) == 0 {
+		content := fmt.Sprintf("No game servers found for this guild. Make sure deployments/statefulsets have the label `%s` and annotation `%sguilds` containing this guild ID (%s)",
+			config.Servers.LabelSelector, config.Servers.AnnotationPrefix, guildID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
 		})
 		return
 	}
 
-	// Filter and process Deployments by guild ID
-	foundServers := false
-	for _, deployment := range deployments.Items {
-		// Check if this deployment belongs to the current guild
-		if !isGuildAuthorized(deployment.Annotations, guildID) {
-			continue
-		}
-		foundServers = true
+	players := lookupAllServerPlayers(servers)
 
+	for idx, server := range servers {
 		statusEmoji := "🔴"
 		status := "stopped"
 
-		if deployment.Status.ReadyReplicas > 0 {
+		if server.ReadyReplicas > 0 {
 			statusEmoji = "🟢"
 			status = "running"
 		}
 
-		serverName := deployment.Name
-		if displayName, ok := deployment.Labels["app.kubernetes.io/name"]; ok {
-			serverName = displayName
+		content += fmt.Sprintf("%s **%s** (%s) - %s (%d/%d replicas)",
+			statusEmoji, server.DisplayName, server.ID(), status,
+			server.ReadyReplicas, server.Replicas)
+		if players[idx] != nil {
+			content += " - " + describePlayers(players[idx], playersListMaxNames)
 		}
+		content += "\n"
 
-		content += fmt.Sprintf("%s **%s** (%s/%s) - %s (%d/%d replicas)\n",
-			statusEmoji, serverName, deployment.Namespace, deployment.Name, status,
-			deployment.Status.ReadyReplicas, deployment.Status.Replicas)
-	}
+		if showUsage && server.ReadyReplicas > 0 && server.onKubernetes() {
+			if usage, err := serverUsage(server); err != nil {
+				log.Printf("Failed to get usage for %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+			} else {
+				content += fmt.Sprintf("    ↳ 📊 %s\n", describeUsage(usage))
+			}
+		}
 
-	// Filter and process StatefulSets by guild ID
-	for _, statefulSet := range statefulSets.Items {
-		// Check if this statefulSet belongs to the current guild
-		if !isGuildAuthorized(statefulSet.Annotations, guildID) {
+		if server.ReadyReplicas == 0 {
 			continue
 		}
-		foundServers = true
-
-		statusEmoji := "🔴"
-		status := "stopped"
-
-		if statefulSet.Status.ReadyReplicas > 0 {
-			statusEmoji = "🟢"
-			status = "running"
+		// The ID without the name is the namespace, qualified with the cluster for named clusters
+		namespaceKey := strings.TrimSuffix(server.ID(), "/"+server.Name)
+		if _, ok := services[namespaceKey]; !ok && server.onKubernetes() {
+			namespaceServices, err := listNamespaceServices(server.cluster(), server.Namespace)
+			if err != nil {
+				log.Printf("Failed to list services in %s for user %s in guild %s: %v", namespaceKey, i.Member.User.ID, i.GuildID, err)
+			}
+			services[namespaceKey] = namespaceServices
 		}
-
-		serverName := statefulSet.Name
-		if displayName, ok := statefulSet.Labels["app.kubernetes.io/name"]; ok {
-			serverName = displayName
+		if addresses := serverConnectAddresses(config, server, services[namespaceKey]); len(addresses) > 0 {
+			content += fmt.Sprintf("    ↳ Connect: %s\n", strings.Join(addresses, ", "))
 		}
-
-		content += fmt.Sprintf("%s **%s** (%s/%s) - %s (%d/%d replicas)\n",
-			statusEmoji, serverName, statefulSet.Namespace, statefulSet.Name, status,
-			statefulSet.Status.ReadyReplicas, statefulSet.Status.Replicas)
 	}
-
-	if !foundServers {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("No game servers found for this guild. Make sure deployments/statefulsets have the label `juicecloud.org/juicebot-game-server=true` and annotation `juicecloud.org/juicebot-guilds` containing this guild ID (%s)", guildID),
-			},
-		})
-		return
+	// Servers in clusters that couldn't be reached are missing from the list
+	if err != nil {
+		content += "⚠️ Some servers couldn't be listed, a cluster may be unreachable\n"
 	}
 
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: content,
-		},
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
 	})
 }
 
-func handleStartServer(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+func handleStartServer(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
 	if len(options) == 0 {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
-				Content: "Please specify a server ID to start (format: games/name)",
+				Content: "Please specify a server ID to start (format: namespace/name)",
 			},
 		})
 		return
@@ -261,169 +1007,91 @@ func handleStartServer(s *discordgo.Session, i *discordgo.InteractionCreate, opt
 
 	serverID := options[0].StringValue()
 
-	if k8sClient == nil {
-		if err := initKubernetesClient(); err != nil {
-			log.Printf("Failed to initialize Kubernetes client for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "❌ Unable to connect to game servers",
-				},
-			})
-			return
-		}
-	}
+	// Defer so the response can follow the server until it is ready
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
 
-	// Parse namespace/name from serverID
-	parts := strings.Split(serverID, "/")
-	if len(parts) != 2 {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "❌ Server ID must be in format: games/name",
-			},
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "start server")
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
 		})
 		return
 	}
 
-	namespace, name := parts[0], parts[1]
-	guildID := i.GuildID
+	if !checkServerRoles(s, i, server, "start", config, db, deferredReply(s, i)) {
+		return
+	}
 
-	// Only allow operations in the games namespace
-	if namespace != "games" {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("❌ Server **%s** not found", serverID),
-			},
+	if server.Replicas > 0 {
+		content := fmt.Sprintf("❌ Server **%s** is already running!", server.Name)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
 		})
 		return
 	}
 
-	// Try to scale deployment first
-	deployment, err := k8sClient.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
-	if err == nil {
-		// Check if it has the required label and belongs to this guild
-		hasLabel := deployment.Labels["juicecloud.org/juicebot-game-server"] == "true"
-		if !hasLabel || !isGuildAuthorized(deployment.Annotations, guildID) {
-			if !hasLabel {
-				log.Printf("User %s in guild %s attempted to access deployment %s/%s without juicebot label", i.Member.User.ID, guildID, namespace, name)
-			} else {
-				annotationValue := deployment.Annotations["juicecloud.org/juicebot-guilds"]
-				log.Printf("User %s in guild %s attempted to access resource %s/%s belonging to guilds %s", i.Member.User.ID, guildID, namespace, name, annotationValue)
-			}
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("❌ Server **%s** not found", serverID),
-				},
-			})
+	// Servers with a vote requirement only start once enough members agree
+	if required := startVotesRequired(server); required > 1 {
+		// Don't hold a vote for a start the quota would refuse
+		if !enforceGuildQuota(s, i, server, config, db, editDeferredResponse(s, i)) {
 			return
 		}
+		openStartVote(s, i, server, required, config, db)
+		return
+	}
 
-		if *deployment.Spec.Replicas > 0 {
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("❌ Server **%s** is already running!", name),
-				},
-			})
-			return
-		}
+	startServerAndFollow(s, i, server, "command", config, db)
+}
 
-		// Scale to 1 replica
-		replicas := int32(1)
-		deployment.Spec.Replicas = &replicas
-		_, err = k8sClient.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
-		if err != nil {
-			log.Printf("Failed to start deployment %s/%s for user %s in guild %s: %v", namespace, name, i.Member.User.ID, guildID, err)
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "❌ Unable to start server",
-				},
-			})
-			return
-		}
+// Start a server and report on a deferred or updated interaction response, following the rollout on Kubernetes
+func startServerAndFollow(s *discordgo.Session, i *discordgo.InteractionCreate, server *gameServer, source string, config *util.JuiceBotConfig, db *sql.DB) {
+	action := "start"
+	if source != "command" {
+		action = source + " start"
+	}
 
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("🟢 Starting server **%s** (%s)", name, serverID),
-			},
-		})
+	if !enforceGuildQuota(s, i, server, config, db, editDeferredResponse(s, i)) {
 		return
 	}
 
-	// Try StatefulSet if deployment not found
-	statefulSet, err := k8sClient.AppsV1().StatefulSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
-	if err == nil {
-		// Check if it has the required label and belongs to this guild
-		hasLabel := statefulSet.Labels["juicecloud.org/juicebot-game-server"] == "true"
-		if !hasLabel || !isGuildAuthorized(statefulSet.Annotations, guildID) {
-			if !hasLabel {
-				log.Printf("User %s in guild %s attempted to access statefulset %s/%s without juicebot label", i.Member.User.ID, guildID, namespace, name)
-			} else {
-				annotationValue := statefulSet.Annotations["juicecloud.org/juicebot-guilds"]
-				log.Printf("User %s in guild %s attempted to access resource %s/%s belonging to guilds %s", i.Member.User.ID, guildID, namespace, name, annotationValue)
-			}
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("❌ Server **%s** not found", serverID),
-				},
-			})
-			return
-		}
+	startedAt := time.Now()
+	err := server.backend.Start(server)
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, action, err, config, db)
+	if err != nil {
+		log.Printf("Failed to start %s %s for user %s in guild %s: %v", strings.ToLower(server.Kind), server.ID(), i.Member.User.ID, i.GuildID, err)
+		content := "❌ Unable to start server"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
 
-		if *statefulSet.Spec.Replicas > 0 {
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("❌ Server **%s** is already running!", name),
-				},
-			})
-			return
-		}
+	log.Printf("User %s in guild %s started %s", i.Member.User.ID, i.GuildID, server.ID())
+	recordServerUsage(db, server, "start", i.Member.User.ID, source)
 
-		// Scale to 1 replica
-		replicas := int32(1)
-		statefulSet.Spec.Replicas = &replicas
-		_, err = k8sClient.AppsV1().StatefulSets(namespace).Update(context.TODO(), statefulSet, metav1.UpdateOptions{})
-		if err != nil {
-			log.Printf("Failed to start deployment %s/%s for user %s in guild %s: %v", namespace, name, i.Member.User.ID, guildID, err)
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "❌ Unable to start server",
-				},
-			})
-			return
+	// Only Kubernetes servers have pods to follow
+	if !server.onKubernetes() {
+		content := fmt.Sprintf("🟢 Started server **%s** (%s)", server.Name, server.ID())
+		if addresses := serverConnectAddresses(config, server, nil); len(addresses) > 0 {
+			content += "\nConnect: " + strings.Join(addresses, ", ")
 		}
-
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("🟢 Starting server **%s** (%s)", name, serverID),
-			},
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
 		})
 		return
 	}
-
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: fmt.Sprintf("❌ Server **%s** not found", serverID),
-		},
-	})
+	go followServerRollout(s, i, server, startedAt, "start", config)
 }
 
-func handleStopServer(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) {
+func handleStopServer(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
 	if len(options) == 0 {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
-				Content: "Please specify a server ID to stop (format: games/name)",
+				Content: "Please specify a server ID to stop (format: namespace/name)",
 			},
 		})
 		return
@@ -431,159 +1099,47 @@ func handleStopServer(s *discordgo.Session, i *discordgo.InteractionCreate, opti
 
 	serverID := options[0].StringValue()
 
-	if k8sClient == nil {
-		if err := initKubernetesClient(); err != nil {
-			log.Printf("Failed to initialize Kubernetes client for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "❌ Unable to connect to game servers",
-				},
-			})
-			return
-		}
-	}
+	// Defer since the lookup, scale and audit can take longer than Discord waits for a response
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
 
-	// Parse namespace/name from serverID
-	parts := strings.Split(serverID, "/")
-	if len(parts) != 2 {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "❌ Server ID must be in format: games/name",
-			},
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "stop server")
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
 		})
 		return
 	}
 
-	namespace, name := parts[0], parts[1]
-	guildID := i.GuildID
-
-	// Only allow operations in the games namespace
-	if namespace != "games" {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("❌ Server **%s** not found", serverID),
-			},
-		})
+	if !checkServerRoles(s, i, server, "stop", config, db, deferredReply(s, i)) {
 		return
 	}
 
-	// Try to scale deployment first
-	deployment, err := k8sClient.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
-	if err == nil {
-		// Check if it has the required label and belongs to this guild
-		hasLabel := deployment.Labels["juicecloud.org/juicebot-game-server"] == "true"
-		if !hasLabel || !isGuildAuthorized(deployment.Annotations, guildID) {
-			if !hasLabel {
-				log.Printf("User %s in guild %s attempted to access deployment %s/%s without juicebot label", i.Member.User.ID, guildID, namespace, name)
-			} else {
-				annotationValue := deployment.Annotations["juicecloud.org/juicebot-guilds"]
-				log.Printf("User %s in guild %s attempted to access resource %s/%s belonging to guilds %s", i.Member.User.ID, guildID, namespace, name, annotationValue)
-			}
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("❌ Server **%s** not found", serverID),
-				},
-			})
-			return
-		}
-
-		if *deployment.Spec.Replicas == 0 {
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("❌ Server **%s** is already stopped!", name),
-				},
-			})
-			return
-		}
-
-		// Scale to 0 replicas
-		replicas := int32(0)
-		deployment.Spec.Replicas = &replicas
-		_, err = k8sClient.AppsV1().Deployments(namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
-		if err != nil {
-			log.Printf("Failed to stop deployment %s/%s for user %s in guild %s: %v", namespace, name, i.Member.User.ID, guildID, err)
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "❌ Unable to stop server",
-				},
-			})
-			return
-		}
-
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("🔴 Stopping server **%s** (%s)", name, serverID),
-			},
+	if server.Replicas == 0 {
+		content := fmt.Sprintf("❌ Server **%s** is already stopped!", server.Name)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
 		})
 		return
 	}
 
-	// Try StatefulSet if deployment not found
-	statefulSet, err := k8sClient.AppsV1().StatefulSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
-	if err == nil {
-		// Check if it has the required label and belongs to this guild
-		hasLabel := statefulSet.Labels["juicecloud.org/juicebot-game-server"] == "true"
-		if !hasLabel || !isGuildAuthorized(statefulSet.Annotations, guildID) {
-			if !hasLabel {
-				log.Printf("User %s in guild %s attempted to access statefulset %s/%s without juicebot label", i.Member.User.ID, guildID, namespace, name)
-			} else {
-				annotationValue := statefulSet.Annotations["juicecloud.org/juicebot-guilds"]
-				log.Printf("User %s in guild %s attempted to access resource %s/%s belonging to guilds %s", i.Member.User.ID, guildID, namespace, name, annotationValue)
-			}
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("❌ Server **%s** not found", serverID),
-				},
-			})
-			return
-		}
-
-		if *statefulSet.Spec.Replicas == 0 {
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: fmt.Sprintf("❌ Server **%s** is already stopped!", name),
-				},
-			})
-			return
-		}
-
-		// Scale to 0 replicas
-		replicas := int32(0)
-		statefulSet.Spec.Replicas = &replicas
-		_, err = k8sClient.AppsV1().StatefulSets(namespace).Update(context.TODO(), statefulSet, metav1.UpdateOptions{})
-		if err != nil {
-			log.Printf("Failed to stop deployment %s/%s for user %s in guild %s: %v", namespace, name, i.Member.User.ID, guildID, err)
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "❌ Unable to stop server",
-				},
-			})
-			return
-		}
-
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("🔴 Stopping server **%s** (%s)", name, serverID),
-			},
+	err = server.backend.Stop(server)
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, "stop", err, config, db)
+	if err != nil {
+		log.Printf("Failed to stop %s %s for user %s in guild %s: %v", strings.ToLower(server.Kind), server.ID(), i.Member.User.ID, i.GuildID, err)
+		content := "❌ Unable to stop server"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
 		})
 		return
 	}
 
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: fmt.Sprintf("❌ Server **%s** not found", serverID),
-		},
+	log.Printf("User %s in guild %s stopped %s", i.Member.User.ID, i.GuildID, server.ID())
+	recordServerUsage(db, server, "stop", i.Member.User.ID, "command")
+	content := fmt.Sprintf("🔴 Stopping server **%s** (%s)", server.Name, server.ID())
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
 	})
 }
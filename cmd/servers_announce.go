@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+)
+
+// Look up the configured games channel for a guild
+func gamesChannelForGuild(config *util.JuiceBotConfig, guildID string) (string, bool) {
+	for _, channel := range config.Games.Channels {
+		if channel.GuildID == guildID {
+			return channel.ChannelID, true
+		}
+	}
+	return "", false
+}
+
+// Post a message to the games channel of every guild a server belongs to
+func announceToServerGuilds(s *discordgo.Session, config *util.JuiceBotConfig, server *gameServer, msg *discordgo.MessageSend) []*discordgo.Message {
+	var sent []*discordgo.Message
+	for _, guildID := range server.Guilds() {
+		channelID, ok := gamesChannelForGuild(config, guildID)
+		if !ok {
+			continue
+		}
+		message, err := s.ChannelMessageSendComplex(channelID, msg)
+		if err != nil {
+			log.Printf("Failed to announce %s to guild %s: %v", server.ID(), guildID, err)
+			continue
+		}
+		sent = append(sent, message)
+	}
+	return sent
+}
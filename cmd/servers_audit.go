@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+)
+
+const historyPageSize = 10
+
+// Audit results
+const (
+	auditSuccess = "success"
+	auditFailed  = "failed"
+	auditDenied  = "denied"
+)
+
+// Look up the configured audit channel for a guild
+func auditChannelForGuild(config *util.JuiceBotConfig, guildID string) (string, bool) {
+	for _, channel := range config.Servers.AuditChannels {
+		if channel.GuildID == guildID {
+			return channel.ChannelID, true
+		}
+	}
+	return "", false
+}
+
+// Record a server action in the audit table and mirror it to the audit channel. Actions nobody
+// asked for, like schedules, have no guild and are recorded for every guild the server belongs to.
+func auditServerAction(s *discordgo.Session, server *gameServer, guildID string, userID string, action string, err error, config *util.JuiceBotConfig, db *sql.DB) {
+	auditServerResult(s, server, guildID, userID, action, auditResultFor(err), err, config, db)
+}
+
+func auditResultFor(err error) string {
+	if err != nil {
+		return auditFailed
+	}
+	return auditSuccess
+}
+
+func auditServerResult(s *discordgo.Session, server *gameServer, guildID string, userID string, action string, result string, err error, config *util.JuiceBotConfig, db *sql.DB) {
+	guilds := []string{guildID}
+	if guildID == "" {
+		guilds = server.Guilds()
+	}
+
+	for _, guild := range guilds {
+		entry := util.ServerAuditEntry{
+			GuildID:  guild,
+			UserID:   userID,
+			ServerID: server.ID(),
+			Action:   action,
+			Result:   result,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		if err := util.AddServerAuditEntry(db, entry); err != nil {
+			log.Printf("Failed to audit %s of %s in guild %s: %v", action, server.ID(), guild, err)
+		}
+
+		channelID, ok := auditChannelForGuild(config, guild)
+		if !ok {
+			continue
+		}
+		_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content: describeAuditEntry(entry, false),
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Parse: []discordgo.AllowedMentionType{},
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to mirror audit entry to channel %s in guild %s: %v", channelID, guild, err)
+		}
+	}
+}
+
+func describeAuditEntry(entry util.ServerAuditEntry, withTime bool) string {
+	emoji := "✅"
+	switch entry.Result {
+	case auditFailed:
+		emoji = "❌"
+	case auditDenied:
+		emoji = "⛔"
+	}
+
+	who := "JuiceBot"
+	if entry.UserID != "" {
+		who = fmt.Sprintf("<@%s>", entry.UserID)
+	}
+
+	line := fmt.Sprintf("%s %s **%s** `%s`", emoji, who, entry.Action, entry.ServerID)
+	if entry.Result != auditSuccess {
+		line += " - " + entry.Result
+	}
+	if entry.Error != "" {
+		line += ": " + entry.Error
+	}
+	if withTime {
+		line = fmt.Sprintf("<t:%d:f> %s", entry.CreatedAt.Unix(), line)
+	}
+	return line
+}
+
+// Render one page of a guild's history with previous/next buttons
+func buildHistoryPage(guildID string, serverID string, page int, db *sql.DB) (string, []discordgo.MessageComponent, error) {
+	// Fetch one extra entry to know whether there is a next page
+	entries, err := util.GetServerAudit(db, guildID, serverID, historyPageSize+1, page*historyPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+	hasNext := len(entries) > historyPageSize
+	if hasNext {
+		entries = entries[:historyPageSize]
+	}
+
+	title := "**Server history**"
+	if serverID != "" {
+		title = fmt.Sprintf("**History for %s**", serverID)
+	}
+	content := fmt.Sprintf("%s (page %d)\n", title, page+1)
+	if len(entries) == 0 {
+		content += "No actions recorded"
+	}
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, truncateLine(describeAuditEntry(entry, true), 180))
+	}
+	content += strings.Join(lines, "\n")
+
+	if page == 0 && !hasNext {
+		return content, []discordgo.MessageComponent{}, nil
+	}
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Newer",
+					Style:    discordgo.SecondaryButton,
+					CustomID: serverCustomID(fmt.Sprintf("servers:history:%d:", page-1), serverID),
+					Disabled: page == 0,
+				},
+				discordgo.Button{
+					Label:    "Older",
+					Style:    discordgo.SecondaryButton,
+					CustomID: serverCustomID(fmt.Sprintf("servers:history:%d:", page+1), serverID),
+					Disabled: !hasNext,
+				},
+			},
+		},
+	}
+	return content, components, nil
+}
+
+// Keep a history line from eating the 2000 character message limit
+func truncateLine(line string, limit int) string {
+	runes := []rune(line)
+	if len(runes) <= limit {
+		return line
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+func handleServerHistory(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
+	serverID := ""
+	for _, opt := range options {
+		if opt.Name == "server" {
+			serverID = strings.TrimSpace(opt.StringValue())
+		}
+	}
+
+	content, components, err := buildHistoryPage(i.GuildID, serverID, 0, db)
+	if err != nil {
+		log.Printf("Failed to get server history for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Unable to retrieve server history",
+			},
+		})
+		return
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: components,
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Parse: []discordgo.AllowedMentionType{},
+			},
+		},
+	})
+}
+
+// Page buttons carry "<page>:<server id>" after the action, the server ID may be empty
+func handleHistoryButton(s *discordgo.Session, i *discordgo.InteractionCreate, value string, config *util.JuiceBotConfig, db *sql.DB) {
+	pageValue, serverID, _ := strings.Cut(value, ":")
+	page, err := strconv.Atoi(pageValue)
+	if err != nil || page < 0 {
+		return
+	}
+	serverID = resolveButtonServerID(config, i.GuildID, serverID)
+
+	content, components, err := buildHistoryPage(i.GuildID, serverID, page, db)
+	if err != nil {
+		log.Printf("Failed to get server history for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Unable to retrieve server history",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: components,
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Parse: []discordgo.AllowedMentionType{},
+			},
+		},
+	})
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+)
+
+// Discord accepts at most 25 autocomplete choices with names up to 100 characters
+const (
+	autocompleteMaxChoices = 25
+	autocompleteMaxName    = 100
+)
+
+func handleServersAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, config *util.JuiceBotConfig) {
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return
+	}
+	subcommand := options[0]
+	// Groups like config hold the subcommand being typed
+	if subcommand.Type == discordgo.ApplicationCommandOptionSubCommandGroup {
+		if len(subcommand.Options) == 0 {
+			return
+		}
+		subcommand = subcommand.Options[0]
+	}
+
+	var typed, serverID string
+	focusedKey := false
+	for _, opt := range subcommand.Options {
+		if opt.Focused {
+			typed = strings.ToLower(strings.TrimSpace(opt.StringValue()))
+			focusedKey = opt.Name == "key"
+		} else if opt.Name == "server" {
+			serverID = opt.StringValue()
+		}
+	}
+
+	if focusedKey {
+		respondAutocomplete(s, i, configKeyChoices(config, i.GuildID, i.Member.User.ID, serverID, typed))
+		return
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+
+	servers, err := listGameServers(config, i.GuildID)
+	if err != nil {
+		log.Printf("Failed to list game servers for autocomplete in guild %s: %v", i.GuildID, err)
+	}
+
+	sort.Slice(servers, func(a, b int) bool {
+		return servers[a].DisplayName < servers[b].DisplayName
+	})
+
+	for _, server := range servers {
+		// start only offers stopped servers and stop only offers running ones
+		if subcommand.Name == "start" && server.Replicas > 0 {
+			continue
+		}
+		if subcommand.Name == "stop" && server.Replicas == 0 {
+			continue
+		}
+		if !matchesServerPrefix(server, typed) {
+			continue
+		}
+
+		name := fmt.Sprintf("%s (%s) - %s", server.DisplayName, server.ID(), server.State())
+		if len(name) > autocompleteMaxName {
+			name = name[:autocompleteMaxName]
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: server.ID(),
+		})
+		if len(choices) == autocompleteMaxChoices {
+			break
+		}
+	}
+
+	respondAutocomplete(s, i, choices)
+}
+
+func respondAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, choices []*discordgo.ApplicationCommandOptionChoice) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to respond to autocomplete in guild %s: %v", i.GuildID, err)
+	}
+}
+
+// Offer the editable settings of the server picked in the same command
+func configKeyChoices(config *util.JuiceBotConfig, guildID string, userID string, serverID string, typed string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	if serverID == "" {
+		return choices
+	}
+	server, err := findGameServer(config, serverID, guildID, userID)
+	if err != nil {
+		return choices
+	}
+	value, ok := server.Annotation("config-keys")
+	if !ok {
+		return choices
+	}
+	keys, err := parseConfigKeys(value)
+	if err != nil {
+		log.Printf("Failed to parse settings of %s for autocomplete in guild %s: %v", server.ID(), guildID, err)
+		return choices
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(strings.ToLower(key.Name), typed) {
+			continue
+		}
+		name := fmt.Sprintf("%s (%s)", key.Name, key.describeType())
+		if len(name) > autocompleteMaxName {
+			name = name[:autocompleteMaxName]
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: key.Name,
+		})
+		if len(choices) == autocompleteMaxChoices {
+			break
+		}
+	}
+	return choices
+}
+
+// Match what the user typed against the server ID, name, or display name
+func matchesServerPrefix(server *gameServer, typed string) bool {
+	if typed == "" {
+		return true
+	}
+	for _, candidate := range []string{server.ID(), server.Name, server.DisplayName} {
+		if strings.HasPrefix(strings.ToLower(candidate), typed) {
+			return true
+		}
+	}
+	return false
+}
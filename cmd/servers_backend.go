@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GameServerBackend is somewhere game servers live, like a Kubernetes cluster or the bot's own host
+type GameServerBackend interface {
+	// List the servers that belong to a guild
+	List(config *util.JuiceBotConfig, guildID string) ([]*gameServer, error)
+	// Get resolves a server ID, returning errServerNotFound if the backend doesn't have it
+	// or the guild isn't allowed to see it
+	Get(config *util.JuiceBotConfig, serverID string, guildID string, userID string) (*gameServer, error)
+	Start(server *gameServer) error
+	Stop(server *gameServer) error
+	// Status renders the backend specific details of a server for /servers status
+	Status(server *gameServer) (*discordgo.MessageEmbed, error)
+}
+
+// kubernetesBackend manages labelled Deployments, StatefulSets and custom resources in one cluster
+type kubernetesBackend struct {
+	// Qualifies server IDs as <cluster>/<namespace>/<name>, empty for the
+	// single cluster used when none are configured
+	Name       string
+	Kubeconfig string
+	Context    string
+	InCluster  bool
+	// Guilds that use the cluster, every guild if empty
+	Guilds []string
+
+	mu      sync.Mutex
+	client  *kubernetes.Clientset
+	dynamic dynamic.Interface
+}
+
+var (
+	clustersOnce sync.Once
+	clusters     []*kubernetesBackend
+)
+
+// The clusters from config. Config doesn't change while the bot runs, so they are built once.
+func kubernetesClusters(config *util.JuiceBotConfig) []*kubernetesBackend {
+	clustersOnce.Do(func() {
+		if len(config.Servers.Clusters) == 0 {
+			clusters = []*kubernetesBackend{{}}
+			return
+		}
+		for _, cluster := range config.Servers.Clusters {
+			clusters = append(clusters, &kubernetesBackend{
+				Name:       cluster.Name,
+				Kubeconfig: cluster.Kubeconfig,
+				Context:    cluster.Context,
+				InCluster:  cluster.InCluster,
+				Guilds:     cluster.Guilds,
+			})
+		}
+	})
+	return clusters
+}
+
+// Connect on first use so the bot still starts without a cluster
+func (b *kubernetesBackend) connect() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.client == nil {
+		return b.initClient()
+	}
+	return nil
+}
+
+func (b *kubernetesBackend) servesGuild(guildID string) bool {
+	return len(b.Guilds) == 0 || slices.Contains(b.Guilds, guildID)
+}
+
+// The cluster's name for logs, "default" for the unnamed cluster
+func (b *kubernetesBackend) displayName() string {
+	if b.Name == "" {
+		return "default"
+	}
+	return b.Name
+}
+
+// Name a namespace in logs and errors, with the cluster for named clusters
+func (b *kubernetesBackend) describeNamespace(namespace string) string {
+	if b.Name == "" {
+		return namespace
+	}
+	return b.Name + "/" + namespace
+}
+
+// Connect to every configured cluster and check the API server answers, so
+// misconfigured clusters show up in the logs at startup rather than on first use
+func CheckKubernetesClusters(config *util.JuiceBotConfig) {
+	if config.Servers.DisableKubernetes {
+		return
+	}
+	if err := validateClusters(config); err != nil {
+		log.Fatalf("Invalid cluster config: %v", err)
+	}
+
+	for _, cluster := range kubernetesClusters(config) {
+		if err := cluster.connect(); err != nil {
+			log.Printf("Failed to connect to cluster %s: %v", cluster.displayName(), err)
+			continue
+		}
+		version, err := cluster.client.Discovery().ServerVersion()
+		if err != nil {
+			log.Printf("Cluster %s failed its health check: %v", cluster.displayName(), err)
+			continue
+		}
+		log.Printf("Connected to cluster %s running Kubernetes %s", cluster.displayName(), version.GitVersion)
+	}
+}
+
+// Cluster names become the first part of server IDs, so they must be unique and
+// can't contain a slash. Only a lone cluster may go unnamed, keeping two-part IDs.
+func validateClusters(config *util.JuiceBotConfig) error {
+	seen := map[string]bool{}
+	for idx, cluster := range config.Servers.Clusters {
+		switch {
+		case cluster.Name == "" && len(config.Servers.Clusters) > 1:
+			return fmt.Errorf("cluster %d has no name, every cluster needs one when there are several", idx+1)
+		case strings.Contains(cluster.Name, "/"):
+			return fmt.Errorf("cluster name %q can't contain /", cluster.Name)
+		case seen[cluster.Name]:
+			return fmt.Errorf("cluster name %q is used more than once", cluster.Name)
+		}
+		seen[cluster.Name] = true
+	}
+	return nil
+}
+
+func (b *kubernetesBackend) Start(server *gameServer) error {
+	return scaleGameServer(server, 1)
+}
+
+func (b *kubernetesBackend) Stop(server *gameServer) error {
+	return scaleGameServer(server, 0)
+}
+
+func (b *kubernetesBackend) Status(server *gameServer) (*discordgo.MessageEmbed, error) {
+	return buildServerStatusEmbed(server)
+}
+
+// The backends enabled in config, in the order servers are listed
+func gameServerBackends(config *util.JuiceBotConfig) []GameServerBackend {
+	var backends []GameServerBackend
+	if !config.Servers.DisableKubernetes {
+		for _, cluster := range kubernetesClusters(config) {
+			backends = append(backends, cluster)
+		}
+	}
+	if len(config.Servers.Processes) > 0 {
+		backends = append(backends, localProcesses)
+	}
+	return backends
+}
+
+// The message for a failed findGameServer. Errors other than a malformed or unknown ID are
+// logged and reported as "Unable to <failure>", e.g. "start server".
+func describeLookupError(i *discordgo.InteractionCreate, serverID string, err error, failure string) string {
+	switch {
+	case errors.Is(err, errServerIDFormat):
+		return "❌ Server ID must be in format: namespace/name or cluster/namespace/name"
+	case errors.Is(err, errServerNotFound):
+		return fmt.Sprintf("❌ Server **%s** not found", serverID)
+	}
+	log.Printf("Failed to look up server %s for user %s in guild %s: %v", serverID, i.Member.User.ID, i.GuildID, err)
+	return "❌ Unable to " + failure
+}
+
+// Resolve a server ID against every backend
+func findGameServer(config *util.JuiceBotConfig, serverID string, guildID string, userID string) (*gameServer, error) {
+	for _, backend := range gameServerBackends(config) {
+		server, err := backend.Get(config, serverID, guildID, userID)
+		if errors.Is(err, errServerNotFound) {
+			continue
+		}
+		return server, err
+	}
+	return nil, errServerNotFound
+}
+
+// List a guild's servers from every backend. Servers from backends that
+// worked are still returned alongside the errors of those that didn't.
+func listGameServers(config *util.JuiceBotConfig, guildID string) ([]*gameServer, error) {
+	var servers []*gameServer
+	var errs []error
+	for _, backend := range gameServerBackends(config) {
+		backendServers, err := backend.List(config, guildID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		servers = append(servers, backendServers...)
+	}
+	return servers, errors.Join(errs...)
+}
+
+// Pods, logs, schedules and the like only exist for servers in a cluster
+func (g *gameServer) onKubernetes() bool {
+	return g.cluster() != nil
+}
+
+// The cluster a server lives in, nil for servers on other backends
+func (g *gameServer) cluster() *kubernetesBackend {
+	cluster, _ := g.backend.(*kubernetesBackend)
+	return cluster
+}
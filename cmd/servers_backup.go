@@ -0,0 +1,489 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	backupPollInterval = 5 * time.Second
+	backupTimeout      = time.Hour
+	// Rows shown by /servers backups
+	backupsListLimit = 15
+	// Job and VolumeSnapshot names are kept to a DNS label
+	backupMaxNameLength = 63
+)
+
+// Backup statuses
+const (
+	backupRunning   = "running"
+	backupSucceeded = "succeeded"
+	backupFailed    = "failed"
+)
+
+var volumeSnapshotResource = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+
+var (
+	errBackupUnconfigured = errors.New("no backup method configured")
+	// Snapshots are taken of volume claim templates, which only StatefulSets have
+	errSnapshotUnsupported = errors.New("volume snapshots are only supported for statefulsets")
+)
+
+// Backups this process is following. Running rows that aren't in here were left behind by a
+// restart, and are checked on by /servers backups instead.
+var followedBackups = struct {
+	sync.Mutex
+	ids map[int64]bool
+}{ids: map[int64]bool{}}
+
+// backupRun is a backup that has been started in the cluster
+type backupRun struct {
+	Method    string
+	Resources []string
+	// Check on the backup, returning whether it finished and why it failed if it did.
+	// An error without finishing is a failed check, which is worth trying again.
+	poll func() (bool, error)
+}
+
+// Start a backup with the job template from the backup-job-template annotation,
+// or snapshots of the StatefulSet's volumes from the backup-snapshot-class annotation
+func startServerBackup(server *gameServer, startedAt time.Time) (*backupRun, error) {
+	stamp := startedAt.UTC().Format("20060102-150405")
+	if template, ok := server.Annotation("backup-job-template"); ok {
+		return startBackupJob(server, template, stamp)
+	}
+	if class, ok := server.Annotation("backup-snapshot-class"); ok {
+		return startBackupSnapshots(server, class, stamp)
+	}
+	return nil, errBackupUnconfigured
+}
+
+// Name a backup resource "<base>-backup-<stamp>", shortening the base to fit
+func backupResourceName(base string, stamp string) string {
+	suffix := "-backup-" + stamp
+	if len(base)+len(suffix) > backupMaxNameLength {
+		base = strings.TrimRight(base[:backupMaxNameLength-len(suffix)], "-.")
+	}
+	return base + suffix
+}
+
+// Create a Job from the job template of a CronJob, the same way kubectl create job --from does.
+// The CronJob is usually suspended and only exists to hold the template.
+func startBackupJob(server *gameServer, template string, stamp string) (*backupRun, error) {
+	cronJob, err := server.cluster().client.BatchV1().CronJobs(server.Namespace).Get(context.TODO(), template, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup template %s: %v", template, err)
+	}
+
+	annotations := map[string]string{}
+	for key, value := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[key] = value
+	}
+	annotations["cronjob.kubernetes.io/instantiate"] = "manual"
+	annotations[server.AnnotationKey("backup-of")] = server.ID()
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        backupResourceName(server.Name, stamp),
+			Namespace:   server.Namespace,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+			Annotations: annotations,
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+	created, err := server.cluster().client.BatchV1().Jobs(server.Namespace).Create(context.TODO(), job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup job: %v", err)
+	}
+
+	return &backupRun{
+		Method:    "job",
+		Resources: []string{created.Name},
+		poll:      backupPoll(server, "job", []string{created.Name}),
+	}, nil
+}
+
+// Snapshot the first replica's volume from each of the StatefulSet's volume claim templates
+func startBackupSnapshots(server *gameServer, class string, stamp string) (*backupRun, error) {
+	if server.Kind != "StatefulSet" {
+		return nil, errSnapshotUnsupported
+	}
+	statefulSet, err := server.cluster().client.AppsV1().StatefulSets(server.Namespace).Get(context.TODO(), server.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statefulset: %v", err)
+	}
+	if len(statefulSet.Spec.VolumeClaimTemplates) == 0 {
+		return nil, fmt.Errorf("statefulset %s has no volume claim templates", server.Name)
+	}
+
+	run := &backupRun{Method: "snapshot"}
+	for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+		claim := fmt.Sprintf("%s-%s-0", template.Name, statefulSet.Name)
+		snapshot := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]interface{}{
+				"name":      backupResourceName(claim, stamp),
+				"namespace": server.Namespace,
+				"annotations": map[string]interface{}{
+					server.AnnotationKey("backup-of"): server.ID(),
+				},
+			},
+			"spec": map[string]interface{}{
+				"volumeSnapshotClassName": class,
+				"source": map[string]interface{}{
+					"persistentVolumeClaimName": claim,
+				},
+			},
+		}}
+		created, err := server.cluster().dynamic.Resource(volumeSnapshotResource).Namespace(server.Namespace).Create(context.TODO(), snapshot, metav1.CreateOptions{})
+		if err != nil {
+			// A partial backup would have no record, so don't leave its snapshots behind
+			deleteBackupSnapshots(server, run.Resources)
+			return nil, fmt.Errorf("failed to create volume snapshot of %s: %v", claim, err)
+		}
+		run.Resources = append(run.Resources, created.GetName())
+	}
+
+	run.poll = backupPoll(server, "snapshot", run.Resources)
+	return run, nil
+}
+
+func deleteBackupSnapshots(server *gameServer, names []string) {
+	for _, name := range names {
+		err := server.cluster().dynamic.Resource(volumeSnapshotResource).Namespace(server.Namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			log.Printf("Failed to delete volume snapshot %s of incomplete backup of %s: %v", name, server.ID(), err)
+		}
+	}
+}
+
+// Check on a backup's Job or VolumeSnapshots by name, so backups recorded
+// before a restart can be checked the same way as new ones. Only a deleted
+// resource fails the backup, other API errors leave it running.
+func backupPoll(server *gameServer, method string, resources []string) func() (bool, error) {
+	if method == "job" {
+		return func() (bool, error) {
+			job, err := server.cluster().client.BatchV1().Jobs(server.Namespace).Get(context.TODO(), resources[0], metav1.GetOptions{})
+			if k8serrors.IsNotFound(err) {
+				return true, fmt.Errorf("backup job %s was deleted", resources[0])
+			}
+			if err != nil {
+				return false, fmt.Errorf("failed to get backup job: %v", err)
+			}
+			for _, condition := range job.Status.Conditions {
+				if condition.Status != corev1.ConditionTrue {
+					continue
+				}
+				switch condition.Type {
+				case batchv1.JobComplete:
+					return true, nil
+				case batchv1.JobFailed:
+					reason := condition.Message
+					if reason == "" {
+						reason = condition.Reason
+					}
+					return true, fmt.Errorf("backup job failed: %s", reason)
+				}
+			}
+			return false, nil
+		}
+	}
+
+	return func() (bool, error) {
+		for _, name := range resources {
+			snapshot, err := server.cluster().dynamic.Resource(volumeSnapshotResource).Namespace(server.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+			if k8serrors.IsNotFound(err) {
+				return true, fmt.Errorf("volume snapshot %s was deleted", name)
+			}
+			if err != nil {
+				return false, fmt.Errorf("failed to get volume snapshot %s: %v", name, err)
+			}
+			if message, ok, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); ok && message != "" {
+				return true, fmt.Errorf("volume snapshot %s failed: %s", name, message)
+			}
+			if ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse"); !ready {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+}
+
+func describeBackupRun(run *backupRun) string {
+	if run.Method == "job" {
+		return fmt.Sprintf("job `%s`", run.Resources[0])
+	}
+	return fmt.Sprintf("snapshot `%s`", strings.Join(run.Resources, "`, `"))
+}
+
+func handleServerBackup(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
+	if len(options) == 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Please specify a server ID to back up (format: namespace/name)",
+			},
+		})
+		return
+	}
+
+	serverID := options[0].StringValue()
+
+	// Defer so the response can follow the backup until it finishes
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "back up server")
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	if !checkServerRoles(s, i, server, "backup", config, db, deferredReply(s, i)) {
+		return
+	}
+
+	if !server.onKubernetes() {
+		content := fmt.Sprintf("❌ Server **%s** is a %s, which can't be backed up", server.DisplayName, strings.ToLower(server.Kind))
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	startedAt := time.Now()
+	run, err := startServerBackup(server, startedAt)
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, "backup", err, config, db)
+	if err != nil {
+		content := "❌ Unable to back up server"
+		switch {
+		case errors.Is(err, errBackupUnconfigured):
+			content = fmt.Sprintf("❌ Server **%s** has no backup configured, set the `%s` or `%s` annotation",
+				server.DisplayName, server.AnnotationKey("backup-job-template"), server.AnnotationKey("backup-snapshot-class"))
+		case errors.Is(err, errSnapshotUnsupported):
+			content = fmt.Sprintf("❌ Server **%s** is a %s, volume snapshots are only supported for statefulsets", server.DisplayName, server.Kind)
+		default:
+			log.Printf("Failed to back up %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		}
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	log.Printf("User %s in guild %s started a backup of %s with %s %v", i.Member.User.ID, i.GuildID, server.ID(), run.Method, run.Resources)
+	backupID, err := util.AddServerBackup(db, util.ServerBackup{
+		GuildID:   i.GuildID,
+		UserID:    i.Member.User.ID,
+		ServerID:  server.ID(),
+		Method:    run.Method,
+		Resources: strings.Join(run.Resources, ","),
+		Status:    backupRunning,
+	})
+	if err != nil {
+		// The backup is still followed for the response, there's just no row to keep up to date
+		log.Printf("Failed to record backup of %s: %v", server.ID(), err)
+	} else {
+		followedBackups.Lock()
+		followedBackups.ids[backupID] = true
+		followedBackups.Unlock()
+	}
+	go followServerBackup(s, i, server, run, backupID, startedAt, db)
+}
+
+// Poll a backup until it finishes, keeping the response and the database up to date.
+// Backups can outlive the interaction token, in which case the result is posted to the channel instead.
+func followServerBackup(s *discordgo.Session, i *discordgo.InteractionCreate, server *gameServer, run *backupRun, backupID int64, startedAt time.Time, db *sql.DB) {
+	content := fmt.Sprintf("💾 Backing up **%s** (%s) with %s", server.DisplayName, server.ID(), describeBackupRun(run))
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+
+	var err error
+	for {
+		var done bool
+		done, err = run.poll()
+		if done {
+			break
+		}
+		if err != nil {
+			log.Printf("Failed to check on backup of %s, trying again: %v", server.ID(), err)
+		}
+		if time.Since(startedAt) > backupTimeout {
+			err = fmt.Errorf("timed out after %s", backupTimeout)
+			break
+		}
+		time.Sleep(backupPollInterval)
+	}
+
+	status := backupSucceeded
+	errMessage := ""
+	if err != nil {
+		status = backupFailed
+		errMessage = err.Error()
+		log.Printf("Backup of %s for user %s in guild %s failed: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		content = fmt.Sprintf("❌ Backup of **%s** (%s) failed: %v", server.DisplayName, server.ID(), err)
+	} else {
+		log.Printf("Backup of %s for user %s in guild %s finished", server.ID(), i.Member.User.ID, i.GuildID)
+		content = fmt.Sprintf("✅ Backed up **%s** (%s) with %s in %s", server.DisplayName, server.ID(), describeBackupRun(run), time.Since(startedAt).Round(time.Second))
+	}
+	if backupID != 0 {
+		if err := util.FinishServerBackup(db, backupID, status, errMessage); err != nil {
+			log.Printf("Failed to record backup result of %s: %v", server.ID(), err)
+		}
+		followedBackups.Lock()
+		delete(followedBackups.ids, backupID)
+		followedBackups.Unlock()
+	}
+
+	if time.Since(startedAt) < rolloutMaxTimeout {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+	_, err = s.ChannelMessageSendComplex(i.ChannelID, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<@%s> %s", i.Member.User.ID, content),
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Users: []string{i.Member.User.ID},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to post backup result of %s to channel %s: %v", server.ID(), i.ChannelID, err)
+	}
+}
+
+// Check on a running backup nobody is following, recording the result if it has finished
+func reconcileServerBackup(config *util.JuiceBotConfig, backup *util.ServerBackup, db *sql.DB) {
+	followedBackups.Lock()
+	followed := followedBackups.ids[backup.ID]
+	followedBackups.Unlock()
+	if followed {
+		return
+	}
+
+	var done bool
+	var pollErr error
+	server, err := findGameServer(config, backup.ServerID, backup.GuildID, backup.UserID)
+	switch {
+	case errors.Is(err, errServerNotFound), err == nil && !server.onKubernetes():
+		done, pollErr = true, errors.New("server no longer exists")
+	case err != nil:
+		log.Printf("Failed to look up %s to check on backup %d: %v", backup.ServerID, backup.ID, err)
+		return
+	default:
+		done, pollErr = backupPoll(server, backup.Method, strings.Split(backup.Resources, ","))()
+		if !done && pollErr != nil {
+			log.Printf("Failed to check on backup %d of %s: %v", backup.ID, backup.ServerID, pollErr)
+		}
+	}
+	if !done && time.Since(backup.StartedAt) > backupTimeout {
+		done, pollErr = true, fmt.Errorf("timed out after %s", backupTimeout)
+	}
+	if !done {
+		return
+	}
+
+	backup.Status = backupSucceeded
+	if pollErr != nil {
+		backup.Status = backupFailed
+		backup.Error = pollErr.Error()
+	}
+	log.Printf("Backup %d of %s left running by a restart has %s", backup.ID, backup.ServerID, backup.Status)
+	if err := util.FinishServerBackup(db, backup.ID, backup.Status, backup.Error); err != nil {
+		log.Printf("Failed to record backup result of %s: %v", backup.ServerID, err)
+		return
+	}
+	backup.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+}
+
+func describeServerBackup(backup util.ServerBackup) string {
+	emoji := "⏳"
+	switch backup.Status {
+	case backupSucceeded:
+		emoji = "✅"
+	case backupFailed:
+		emoji = "❌"
+	}
+
+	line := fmt.Sprintf("%s <t:%d:f> **%s** by <@%s> - %s `%s`", emoji, backup.StartedAt.Unix(), backup.ServerID, backup.UserID,
+		backup.Method, strings.ReplaceAll(backup.Resources, ",", "`, `"))
+	switch {
+	case backup.Status == backupRunning:
+		line += " - running"
+	case backup.FinishedAt.Valid:
+		line += fmt.Sprintf(" - took %s", backup.FinishedAt.Time.Sub(backup.StartedAt).Round(time.Second))
+	}
+	if backup.Error != "" {
+		line += ": " + backup.Error
+	}
+	return line
+}
+
+func handleServerBackups(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
+	serverID := ""
+	for _, opt := range options {
+		if opt.Name == "server" {
+			serverID = strings.TrimSpace(opt.StringValue())
+		}
+	}
+
+	// Backups left running by a restart are checked against the cluster, which can take a moment
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	backups, err := util.GetServerBackups(db, i.GuildID, serverID, backupsListLimit)
+	if err != nil {
+		log.Printf("Failed to get server backups for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
+		content := "❌ Unable to retrieve server backups"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	content := "**Server backups:**\n"
+	if serverID != "" {
+		content = fmt.Sprintf("**Backups of %s:**\n", serverID)
+	}
+	if len(backups) == 0 {
+		content += "No backups recorded"
+	}
+	var lines []string
+	for idx := range backups {
+		if backups[idx].Status == backupRunning {
+			reconcileServerBackup(config, &backups[idx], db)
+		}
+		lines = append(lines, truncateLine(describeServerBackup(backups[idx]), 180))
+	}
+	content += strings.Join(lines, "\n")
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Parse: []discordgo.AllowedMentionType{},
+		},
+	})
+}
@@ -0,0 +1,463 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Settings values are shown inline, so keep them to something readable
+const configMaxValueLength = 200
+
+var errConfigUnconfigured = errors.New("no editable settings")
+
+// configKey is an editable ConfigMap key and the values it accepts
+type configKey struct {
+	Name string
+	// string, int, bool, or enum
+	Type   string
+	Min    *int64
+	Max    *int64
+	Values []string
+}
+
+// Parse the config-keys annotation, a comma separated list of keys with optional types:
+// "motd,max-players=int:1..100,pvp=bool,difficulty=enum:peaceful|easy|normal|hard"
+func parseConfigKeys(value string) ([]configKey, error) {
+	var keys []configKey
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, _ := strings.Cut(entry, "=")
+		key := configKey{Name: strings.TrimSpace(name), Type: "string"}
+		if key.Name == "" {
+			return nil, fmt.Errorf("missing key name in %q", entry)
+		}
+		typeName, args, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		switch typeName {
+		case "", "string":
+		case "bool":
+			key.Type = "bool"
+		case "int":
+			key.Type = "int"
+			if args != "" {
+				minValue, maxValue, ok := strings.Cut(args, "..")
+				if !ok {
+					return nil, fmt.Errorf("invalid range %q for %s, expected min..max", args, key.Name)
+				}
+				if minValue != "" {
+					parsed, err := strconv.ParseInt(minValue, 10, 64)
+					if err != nil {
+						return nil, fmt.Errorf("invalid minimum for %s: %v", key.Name, err)
+					}
+					key.Min = &parsed
+				}
+				if maxValue != "" {
+					parsed, err := strconv.ParseInt(maxValue, 10, 64)
+					if err != nil {
+						return nil, fmt.Errorf("invalid maximum for %s: %v", key.Name, err)
+					}
+					key.Max = &parsed
+				}
+			}
+		case "enum":
+			key.Type = "enum"
+			for _, allowed := range strings.Split(args, "|") {
+				if allowed = strings.TrimSpace(allowed); allowed != "" {
+					key.Values = append(key.Values, allowed)
+				}
+			}
+			if len(key.Values) == 0 {
+				return nil, fmt.Errorf("enum %s has no values", key.Name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown type %q for %s", typeName, key.Name)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Check a value against the key's type, returning it in the form it should be stored
+func (k configKey) validate(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch k.Type {
+	case "int":
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", errors.New("must be a whole number")
+		}
+		if (k.Min != nil && parsed < *k.Min) || (k.Max != nil && parsed > *k.Max) {
+			return "", fmt.Errorf("must be %s", k.describeRange())
+		}
+		return strconv.FormatInt(parsed, 10), nil
+	case "bool":
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", errors.New("must be true or false")
+		}
+		return strconv.FormatBool(parsed), nil
+	case "enum":
+		for _, allowed := range k.Values {
+			if strings.EqualFold(value, allowed) {
+				return allowed, nil
+			}
+		}
+		return "", fmt.Errorf("must be one of: %s", strings.Join(k.Values, ", "))
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return "", errors.New("must be a single line")
+	}
+	if len(value) > configMaxValueLength {
+		return "", fmt.Errorf("must be at most %d characters", configMaxValueLength)
+	}
+	return value, nil
+}
+
+func (k configKey) describeRange() string {
+	switch {
+	case k.Min != nil && k.Max != nil:
+		return fmt.Sprintf("between %d and %d", *k.Min, *k.Max)
+	case k.Min != nil:
+		return fmt.Sprintf("at least %d", *k.Min)
+	case k.Max != nil:
+		return fmt.Sprintf("at most %d", *k.Max)
+	}
+	return "a whole number"
+}
+
+// Describe what a key accepts, e.g. "whole number between 1 and 100"
+func (k configKey) describeType() string {
+	switch k.Type {
+	case "int":
+		if k.Min == nil && k.Max == nil {
+			return "whole number"
+		}
+		return "whole number " + k.describeRange()
+	case "bool":
+		return "true or false"
+	case "enum":
+		return strings.Join(k.Values, ", ")
+	}
+	return "text"
+}
+
+// The annotation on the ConfigMap holding a key's value from before JuiceBot first changed it
+func configOriginalAnnotation(server *gameServer, key string) string {
+	return server.AnnotationKey("original-" + key)
+}
+
+// Encode a key's original value as JSON, null for a key that didn't exist,
+// so it can be told apart from one that was set to an empty string
+func encodeConfigOriginal(value string, exists bool) string {
+	if !exists {
+		return "null"
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// Decode an original value from encodeConfigOriginal, reporting whether the key existed.
+// Annotations that aren't JSON are taken as the value itself.
+func decodeConfigOriginal(encoded string) (string, bool) {
+	var value *string
+	if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+		return encoded, true
+	}
+	if value == nil {
+		return "", false
+	}
+	return *value, true
+}
+
+// Look up the ConfigMap from the config-map annotation and the keys that may be edited in it
+func serverConfigMap(server *gameServer) (*corev1.ConfigMap, []configKey, error) {
+	name, ok := server.Annotation("config-map")
+	if !ok || !server.onKubernetes() {
+		return nil, nil, errConfigUnconfigured
+	}
+	value, ok := server.Annotation("config-keys")
+	if !ok {
+		return nil, nil, errConfigUnconfigured
+	}
+	keys, err := parseConfigKeys(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s annotation: %v", server.AnnotationKey("config-keys"), err)
+	}
+	if len(keys) == 0 {
+		return nil, nil, errConfigUnconfigured
+	}
+
+	configMap, err := server.cluster().client.CoreV1().ConfigMaps(server.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get configmap %s: %v", name, err)
+	}
+	return configMap, keys, nil
+}
+
+func findConfigKey(keys []configKey, name string) (configKey, bool) {
+	for _, key := range keys {
+		if key.Name == name {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+// Set keys in a ConfigMap with a merge patch. A nil value removes the key.
+func patchConfigMap(server *gameServer, configMap *corev1.ConfigMap, data map[string]interface{}, annotations map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+		"data": data,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = server.cluster().client.CoreV1().ConfigMaps(configMap.Namespace).Patch(context.TODO(), configMap.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// Buttons offered after a setting changes on a running server, which only reads its config on start
+func configRestartComponents(server *gameServer) []discordgo.MessageComponent {
+	if server.Replicas == 0 || (server.Kind != "Deployment" && server.Kind != "StatefulSet") {
+		return []discordgo.MessageComponent{}
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Restart now",
+					Style:    discordgo.PrimaryButton,
+					CustomID: serverCustomID("servers:configrestart:", server.ID()),
+				},
+			},
+		},
+	}
+}
+
+// Route /servers config get, set, and reset
+func handleServerConfig(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
+	if len(options) == 0 {
+		return
+	}
+	subcommand := options[0]
+
+	var serverID, keyName, value string
+	for _, opt := range subcommand.Options {
+		switch opt.Name {
+		case "server":
+			serverID = opt.StringValue()
+		case "key":
+			keyName = strings.TrimSpace(opt.StringValue())
+		case "value":
+			value = opt.StringValue()
+		}
+	}
+	respond := func(content string, components []discordgo.MessageComponent) {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content:    content,
+				Components: components,
+			},
+		})
+	}
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "retrieve server settings")
+		respond(content, nil)
+		return
+	}
+
+	// Viewing settings is open to anyone who can see the server
+	if subcommand.Name != "get" && !checkServerRoles(s, i, server, "config "+subcommand.Name, config, db, ephemeralReply(s, i)) {
+		return
+	}
+
+	configMap, keys, err := serverConfigMap(server)
+	if err != nil {
+		content := "❌ Unable to retrieve server settings"
+		if errors.Is(err, errConfigUnconfigured) {
+			content = fmt.Sprintf("❌ Server **%s** has no editable settings, set the `%s` and `%s` annotations",
+				server.DisplayName, server.AnnotationKey("config-map"), server.AnnotationKey("config-keys"))
+		} else {
+			log.Printf("Failed to get settings for %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		}
+		respond(content, nil)
+		return
+	}
+
+	var key configKey
+	if keyName != "" {
+		var ok bool
+		if key, ok = findConfigKey(keys, keyName); !ok {
+			var names []string
+			for _, key := range keys {
+				names = append(names, key.Name)
+			}
+			respond(fmt.Sprintf("❌ `%s` isn't an editable setting on **%s**, try one of: %s", keyName, server.DisplayName, strings.Join(names, ", ")), nil)
+			return
+		}
+	}
+
+	switch subcommand.Name {
+	case "get":
+		if keyName != "" {
+			keys = []configKey{key}
+		}
+		content := fmt.Sprintf("**Settings for %s:**\n", server.DisplayName)
+		for _, key := range keys {
+			current, ok := configMap.Data[key.Name]
+			line := fmt.Sprintf("⚙️ `%s` = `%s`", key.Name, current)
+			if !ok {
+				line = fmt.Sprintf("⚙️ `%s` is not set", key.Name)
+			}
+			line += fmt.Sprintf(" (%s)", key.describeType())
+			if encoded, ok := configMap.Annotations[configOriginalAnnotation(server, key.Name)]; ok {
+				if original, existed := decodeConfigOriginal(encoded); existed {
+					line += fmt.Sprintf(", changed from `%s`", original)
+				} else {
+					line += ", was not set before"
+				}
+			}
+			content += truncateLine(line, 400) + "\n"
+		}
+		respond(content, nil)
+
+	case "set":
+		normalized, err := key.validate(value)
+		if err != nil {
+			respond(fmt.Sprintf("❌ `%s` %v", key.Name, err), nil)
+			return
+		}
+		current, exists := configMap.Data[key.Name]
+		if exists && current == normalized {
+			respond(fmt.Sprintf("`%s` is already `%s` on **%s**", key.Name, normalized, server.DisplayName), nil)
+			return
+		}
+
+		// Remember the value from before the first change so it can be reset
+		annotations := map[string]interface{}{}
+		if _, ok := configMap.Annotations[configOriginalAnnotation(server, key.Name)]; !ok {
+			annotations[configOriginalAnnotation(server, key.Name)] = encodeConfigOriginal(current, exists)
+		}
+		err = patchConfigMap(server, configMap, map[string]interface{}{key.Name: normalized}, annotations)
+		auditServerAction(s, server, i.GuildID, i.Member.User.ID, truncateLine(fmt.Sprintf("config set %s=%s", key.Name, normalized), 100), err, config, db)
+		if err != nil {
+			log.Printf("Failed to set %s on %s for user %s in guild %s: %v", key.Name, server.ID(), i.Member.User.ID, i.GuildID, err)
+			respond("❌ Unable to change server setting", nil)
+			return
+		}
+
+		log.Printf("User %s in guild %s set %s to %q on %s", i.Member.User.ID, i.GuildID, key.Name, normalized, server.ID())
+		content := fmt.Sprintf("✅ Set `%s` to `%s` on **%s**", key.Name, normalized, server.DisplayName)
+		components := configRestartComponents(server)
+		if len(components) > 0 {
+			content += "\nThe server needs a restart to pick this up"
+		}
+		respond(content, components)
+
+	case "reset":
+		encoded, ok := configMap.Annotations[configOriginalAnnotation(server, key.Name)]
+		if !ok {
+			respond(fmt.Sprintf("`%s` hasn't been changed on **%s**", key.Name, server.DisplayName), nil)
+			return
+		}
+
+		// Keys that didn't exist before are removed again
+		original, existed := decodeConfigOriginal(encoded)
+		var restored interface{} = original
+		if !existed {
+			restored = nil
+		}
+		err = patchConfigMap(server, configMap, map[string]interface{}{key.Name: restored}, map[string]interface{}{
+			configOriginalAnnotation(server, key.Name): nil,
+		})
+		auditServerAction(s, server, i.GuildID, i.Member.User.ID, "config reset "+key.Name, err, config, db)
+		if err != nil {
+			log.Printf("Failed to reset %s on %s for user %s in guild %s: %v", key.Name, server.ID(), i.Member.User.ID, i.GuildID, err)
+			respond("❌ Unable to reset server setting", nil)
+			return
+		}
+
+		log.Printf("User %s in guild %s reset %s on %s", i.Member.User.ID, i.GuildID, key.Name, server.ID())
+		content := fmt.Sprintf("✅ Reset `%s` to `%s` on **%s**", key.Name, original, server.DisplayName)
+		if !existed {
+			content = fmt.Sprintf("✅ Reset `%s` on **%s**, it is no longer set", key.Name, server.DisplayName)
+		}
+		components := configRestartComponents(server)
+		if len(components) > 0 {
+			content += "\nThe server needs a restart to pick this up"
+		}
+		respond(content, components)
+	}
+}
+
+// Restart a server from the prompt after a settings change, with the same role checks as /servers restart
+func handleConfigRestartButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverID string, config *util.JuiceBotConfig, db *sql.DB) {
+	respondEphemeral := func(content string) {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+				AllowedMentions: &discordgo.MessageAllowedMentions{
+					Parse: []discordgo.AllowedMentionType{},
+				},
+			},
+		})
+	}
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		respondEphemeral(describeLookupError(i, serverID, err, "restart server"))
+		return
+	}
+
+	if !checkServerRoles(s, i, server, "restart", config, db, respondEphemeral) {
+		return
+	}
+
+	if server.Replicas == 0 {
+		respondEphemeral(fmt.Sprintf("❌ Server **%s** is not running, it will use the new settings when started", server.DisplayName))
+		return
+	}
+
+	restartedAt := time.Now()
+	err = restartGameServer(server)
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, "restart", err, config, db)
+	if err != nil {
+		log.Printf("Failed to restart %s %s for user %s in guild %s: %v", strings.ToLower(server.Kind), server.ID(), i.Member.User.ID, i.GuildID, err)
+		respondEphemeral("❌ Unable to restart server")
+		return
+	}
+
+	log.Printf("User %s in guild %s restarted %s after a settings change", i.Member.User.ID, i.GuildID, server.ID())
+	// The prompt becomes the restart progress message
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("🔄 Restarting server **%s** (%s)", server.Name, server.ID()),
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	go followServerRollout(s, i, server, restartedAt, "restart", config)
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func int64Pointer(value int64) *int64 {
+	return &value
+}
+
+func TestParseConfigKeys(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []configKey
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: " , ,", want: nil},
+		{value: "motd", want: []configKey{{Name: "motd", Type: "string"}}},
+		{value: "motd=string", want: []configKey{{Name: "motd", Type: "string"}}},
+		{value: " pvp = bool ", want: []configKey{{Name: "pvp", Type: "bool"}}},
+		{value: "max-players=int", want: []configKey{{Name: "max-players", Type: "int"}}},
+		{value: "max-players=int:1..100", want: []configKey{{Name: "max-players", Type: "int", Min: int64Pointer(1), Max: int64Pointer(100)}}},
+		{value: "view-distance=int:-5..", want: []configKey{{Name: "view-distance", Type: "int", Min: int64Pointer(-5)}}},
+		{value: "spawn-protection=int:..16", want: []configKey{{Name: "spawn-protection", Type: "int", Max: int64Pointer(16)}}},
+		{value: "difficulty=enum:peaceful| easy ||hard", want: []configKey{{Name: "difficulty", Type: "enum", Values: []string{"peaceful", "easy", "hard"}}}},
+		{value: "motd,pvp=bool", want: []configKey{{Name: "motd", Type: "string"}, {Name: "pvp", Type: "bool"}}},
+		{value: "max-players=int:100", wantErr: true},
+		{value: "max-players=int:one..10", wantErr: true},
+		{value: "max-players=int:1..ten", wantErr: true},
+		{value: "difficulty=enum", wantErr: true},
+		{value: "difficulty=enum:|", wantErr: true},
+		{value: "seed=float", wantErr: true},
+		{value: "=int", wantErr: true},
+		{value: "motd, =bool", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseConfigKeys(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseConfigKeys(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !slices.EqualFunc(got, tt.want, configKeysEqual) {
+			t.Errorf("parseConfigKeys(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func configKeysEqual(a, b configKey) bool {
+	pointersEqual := func(a, b *int64) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+	}
+	return a.Name == b.Name && a.Type == b.Type && pointersEqual(a.Min, b.Min) && pointersEqual(a.Max, b.Max) && slices.Equal(a.Values, b.Values)
+}
+
+func TestConfigKeyValidate(t *testing.T) {
+	players := configKey{Name: "max-players", Type: "int", Min: int64Pointer(1), Max: int64Pointer(100)}
+	tests := []struct {
+		key     configKey
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{key: players, value: "20", want: "20"},
+		{key: players, value: " 007 ", want: "7"},
+		{key: players, value: "1", want: "1"},
+		{key: players, value: "100", want: "100"},
+		{key: players, value: "0", wantErr: true},
+		{key: players, value: "101", wantErr: true},
+		{key: players, value: "1.5", wantErr: true},
+		{key: players, value: "", wantErr: true},
+		{key: configKey{Type: "int", Min: int64Pointer(-5)}, value: "-5", want: "-5"},
+		{key: configKey{Type: "int"}, value: "99999999999999999999", wantErr: true},
+		{key: configKey{Type: "bool"}, value: "TRUE", want: "true"},
+		{key: configKey{Type: "bool"}, value: "0", want: "false"},
+		{key: configKey{Type: "bool"}, value: "yes", wantErr: true},
+		{key: configKey{Type: "enum", Values: []string{"peaceful", "easy"}}, value: "Easy", want: "easy"},
+		{key: configKey{Type: "enum", Values: []string{"peaceful", "easy"}}, value: "hard", wantErr: true},
+		{key: configKey{Type: "string"}, value: "  A Minecraft Server  ", want: "A Minecraft Server"},
+		{key: configKey{Type: "string"}, value: "", want: ""},
+		{key: configKey{Type: "string"}, value: "two\nlines", wantErr: true},
+		{key: configKey{Type: "string"}, value: "carriage\rreturn", wantErr: true},
+		{key: configKey{Type: "string"}, value: strings.Repeat("x", configMaxValueLength), want: strings.Repeat("x", configMaxValueLength)},
+		{key: configKey{Type: "string"}, value: strings.Repeat("x", configMaxValueLength+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.key.validate(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validate(%q) as %s error = %v, want error %v", tt.value, tt.key.describeType(), err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("validate(%q) as %s = %q, want %q", tt.value, tt.key.describeType(), got, tt.want)
+		}
+	}
+}
+
+func TestConfigOriginalRoundTrip(t *testing.T) {
+	tests := []struct {
+		value  string
+		exists bool
+	}{
+		{value: "", exists: false},
+		{value: "", exists: true},
+		{value: "A Minecraft Server", exists: true},
+		{value: "null", exists: true},
+		{value: `"quoted"`, exists: true},
+	}
+
+	for _, tt := range tests {
+		value, exists := decodeConfigOriginal(encodeConfigOriginal(tt.value, tt.exists))
+		if value != tt.value || exists != tt.exists {
+			t.Errorf("round trip of (%q, %v) gave (%q, %v)", tt.value, tt.exists, value, exists)
+		}
+	}
+
+	// Annotations written before originals were encoded hold the plain value
+	if value, exists := decodeConfigOriginal("A Minecraft Server"); value != "A Minecraft Server" || !exists {
+		t.Errorf("decodeConfigOriginal of a plain value = (%q, %v), want it unchanged", value, exists)
+	}
+}
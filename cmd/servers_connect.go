@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/clbx/juicebot/util"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func listNamespaceServices(cluster *kubernetesBackend, namespace string) ([]corev1.Service, error) {
+	services, err := cluster.client.CoreV1().Services(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return services.Items, nil
+}
+
+// Look up how players connect to a server
+func connectAddresses(config *util.JuiceBotConfig, server *gameServer) []string {
+	if _, ok := server.Annotation("connect"); ok {
+		return serverConnectAddresses(config, server, nil)
+	}
+
+	services, err := listNamespaceServices(server.cluster(), server.Namespace)
+	if err != nil {
+		log.Printf("Failed to list services for %s: %v", server.ID(), err)
+		return nil
+	}
+	return serverConnectAddresses(config, server, services)
+}
+
+// Build copyable connect strings from the connect annotation, or the Services selecting the server's pods
+func serverConnectAddresses(config *util.JuiceBotConfig, server *gameServer, services []corev1.Service) []string {
+	if override, ok := server.Annotation("connect"); ok {
+		var addresses []string
+		for _, address := range strings.Split(override, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addresses = append(addresses, fmt.Sprintf("`%s`", address))
+			}
+		}
+		return addresses
+	}
+
+	if len(server.PodLabels) == 0 {
+		return nil
+	}
+
+	var addresses []string
+	for _, service := range services {
+		if len(service.Spec.Selector) == 0 {
+			continue
+		}
+		if !labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(server.PodLabels)) {
+			continue
+		}
+
+		switch service.Spec.Type {
+		case corev1.ServiceTypeLoadBalancer:
+			for _, ingress := range service.Status.LoadBalancer.Ingress {
+				host := ingress.IP
+				if host == "" {
+					host = ingress.Hostname
+				}
+				if host == "" {
+					continue
+				}
+				for _, port := range service.Spec.Ports {
+					addresses = append(addresses, formatConnectAddress(host, port.Port, port))
+				}
+			}
+		case corev1.ServiceTypeNodePort:
+			for _, port := range service.Spec.Ports {
+				if port.NodePort == 0 {
+					continue
+				}
+				if config.Servers.NodeAddress != "" {
+					addresses = append(addresses, formatConnectAddress(config.Servers.NodeAddress, port.NodePort, port))
+				} else {
+					addresses = append(addresses, fmt.Sprintf("NodePort `%d`%s", port.NodePort, describeServicePort(port)))
+				}
+			}
+		}
+	}
+	return addresses
+}
+
+func formatConnectAddress(host string, port int32, servicePort corev1.ServicePort) string {
+	return fmt.Sprintf("`%s`%s", net.JoinHostPort(host, strconv.Itoa(int(port))), describeServicePort(servicePort))
+}
+
+// Name the port when a service exposes more than one thing, e.g. (query/UDP)
+func describeServicePort(port corev1.ServicePort) string {
+	if port.Name == "" {
+		return fmt.Sprintf(" (%s)", port.Protocol)
+	}
+	return fmt.Sprintf(" (%s/%s)", port.Name, port.Protocol)
+}
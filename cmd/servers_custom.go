@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/clbx/juicebot/util"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The custom game server resources from config
+func customResources(config *util.JuiceBotConfig) []schema.GroupVersionResource {
+	var resources []schema.GroupVersionResource
+	for _, resource := range config.Servers.CustomResources {
+		resources = append(resources, schema.GroupVersionResource{
+			Group:    resource.Group,
+			Version:  resource.Version,
+			Resource: resource.Resource,
+		})
+	}
+	return resources
+}
+
+// Look a server up among the configured custom resources
+func findCustomGameServer(config *util.JuiceBotConfig, cluster *kubernetesBackend, namespace string, name string) (*gameServer, error) {
+	for _, resource := range customResources(config) {
+		obj, err := cluster.dynamic.Resource(resource).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s %s/%s: %v", resource.Resource, namespace, name, err)
+		}
+
+		server := gameServerFromUnstructured(config, cluster, resource, obj)
+
+		// The scale subresource is authoritative for replicas and knows the pod selector
+		// even for kinds without a spec.selector
+		scale, err := cluster.dynamic.Resource(resource).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{}, "scale")
+		if err != nil {
+			return nil, fmt.Errorf("%s %s/%s is not scalable: %v", resource.Resource, namespace, name, err)
+		}
+		if replicas, ok, _ := unstructured.NestedInt64(scale.Object, "spec", "replicas"); ok {
+			server.Replicas = int32(replicas)
+		}
+		if server.Selector == nil {
+			if selector, ok, _ := unstructured.NestedString(scale.Object, "status", "selector"); ok && selector != "" {
+				if parsed, err := metav1.ParseToLabelSelector(selector); err == nil {
+					server.Selector = parsed
+					if server.PodLabels == nil {
+						server.PodLabels = parsed.MatchLabels
+					}
+				}
+			}
+		}
+		return server, nil
+	}
+	return nil, errServerNotFound
+}
+
+// List labelled custom resources in a namespace
+func listCustomGameServers(config *util.JuiceBotConfig, cluster *kubernetesBackend, namespace string) ([]*gameServer, error) {
+	var servers []*gameServer
+	for _, resource := range customResources(config) {
+		list, err := cluster.dynamic.Resource(resource).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: config.Servers.LabelSelector,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s in %s: %v", resource.Resource, namespace, err)
+		}
+		for idx := range list.Items {
+			servers = append(servers, gameServerFromUnstructured(config, cluster, resource, &list.Items[idx]))
+		}
+	}
+	return servers, nil
+}
+
+// Build a game server from a custom resource, reading the replica fields most scalable kinds share
+func gameServerFromUnstructured(config *util.JuiceBotConfig, cluster *kubernetesBackend, resource schema.GroupVersionResource, obj *unstructured.Unstructured) *gameServer {
+	server := &gameServer{
+		Kind:             obj.GetKind(),
+		Resource:         resource,
+		Namespace:        obj.GetNamespace(),
+		Name:             obj.GetName(),
+		Labels:           obj.GetLabels(),
+		Annotations:      obj.GetAnnotations(),
+		annotationPrefix: config.Servers.AnnotationPrefix,
+		backend:          cluster,
+	}
+	if replicas, ok, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); ok {
+		server.Replicas = int32(replicas)
+	}
+	if ready, ok, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas"); ok {
+		server.ReadyReplicas = int32(ready)
+	}
+	if selector, ok, _ := unstructured.NestedMap(obj.Object, "spec", "selector"); ok {
+		var labelSelector metav1.LabelSelector
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selector, &labelSelector); err == nil {
+			server.Selector = &labelSelector
+		}
+	}
+	if podLabels, ok, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels"); ok {
+		server.PodLabels = podLabels
+	} else if server.Selector != nil {
+		server.PodLabels = server.Selector.MatchLabels
+	}
+	if podSpec, ok, _ := unstructured.NestedMap(obj.Object, "spec", "template", "spec"); ok {
+		var spec corev1.PodSpec
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(podSpec, &spec); err == nil {
+			server.PodRequests = podSpecRequests(spec)
+		}
+	}
+	server.setDisplayName(config)
+	return server
+}
+
+// Scale a custom resource through its /scale subresource
+func scaleCustomGameServer(server *gameServer, replicas int32) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = server.cluster().dynamic.Resource(server.Resource).Namespace(server.Namespace).Patch(context.TODO(), server.Name, types.MergePatchType, patch, metav1.PatchOptions{}, "scale")
+	return err
+}
@@ -0,0 +1,458 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+const (
+	idleCheckInterval = time.Minute
+	// How long before shutdown the guild is warned
+	idleWarningLead = 5 * time.Minute
+	// Bytes per check interval below which the network probe considers a server idle
+	idleDefaultNetworkBytes = 100 * 1024
+	idleQueryTimeout        = 3 * time.Second
+)
+
+// idleState tracks one running server between reaper checks
+type idleState struct {
+	LastActive      time.Time
+	Warned          bool
+	WarningMessages []*discordgo.Message
+
+	// Previous network counter sample, used by the network probe
+	NetworkBytes     uint64
+	HasNetworkSample bool
+}
+
+// idleProbe decides whether a server has seen activity since the last check
+type idleProbe interface {
+	Active(server *gameServer, pods []corev1.Pod, state *idleState) (bool, error)
+}
+
+// Probes selectable with the idle-probe annotation
+var idleProbes = map[string]idleProbe{
+	"uptime":  uptimeProbe{},
+	"players": playersProbe{},
+	"network": networkProbe{},
+}
+
+type idleReaper struct {
+	mu      sync.Mutex
+	servers map[string]*idleState
+}
+
+var reaper = &idleReaper{servers: map[string]*idleState{}}
+
+// Start the background loop that scales idle game servers to zero
+func StartIdleReaper(s *discordgo.Session, config *util.JuiceBotConfig, db *sql.DB) {
+	if config.Servers.DisableKubernetes {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(idleCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			reaper.check(s, config, db)
+		}
+	}()
+}
+
+func (r *idleReaper) check(s *discordgo.Session, config *util.JuiceBotConfig, db *sql.DB) {
+	// Clusters that can't be reached are skipped until they come back
+	servers, err := listAllGameServers(config)
+	if err != nil {
+		log.Printf("Idle reaper failed to list game servers: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, server := range servers {
+		timeoutValue, ok := server.Annotation("idle-timeout")
+		if !ok || server.Replicas == 0 {
+			continue
+		}
+		timeout, err := time.ParseDuration(timeoutValue)
+		if err != nil || timeout <= 0 {
+			log.Printf("Idle reaper ignoring %s, invalid idle timeout %q", server.ID(), timeoutValue)
+			continue
+		}
+		seen[server.ID()] = true
+		r.checkServer(s, config, server, timeout, db)
+	}
+
+	// Forget servers that stopped or lost their annotation. After a failed listing a
+	// server may just be missing, so keep everything until the next full one.
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	for id := range r.servers {
+		if !seen[id] {
+			delete(r.servers, id)
+		}
+	}
+	r.mu.Unlock()
+}
+
+func (r *idleReaper) checkServer(s *discordgo.Session, config *util.JuiceBotConfig, server *gameServer, timeout time.Duration, db *sql.DB) {
+	pods, err := listServerPods(server)
+	if err != nil {
+		log.Printf("Idle reaper failed to list pods for %s: %v", server.ID(), err)
+		return
+	}
+
+	startedAt, started := serverStartTime(server, pods, db)
+	r.mu.Lock()
+	state, ok := r.servers[server.ID()]
+	var stale []*discordgo.Message
+	// A stop and start between checks leaves the last run's state behind, which
+	// shows as pods that started after the server was last seen active
+	if ok && started && startedAt.After(state.LastActive) {
+		stale = state.WarningMessages
+		ok = false
+	}
+	if !ok {
+		state = &idleState{LastActive: startedAt}
+		r.servers[server.ID()] = state
+	}
+	r.mu.Unlock()
+
+	if len(stale) > 0 {
+		editIdleWarnings(s, stale, fmt.Sprintf("🔄 **%s** was restarted, shutdown cancelled", server.DisplayName))
+	}
+
+	probeName, _ := server.Annotation("idle-probe")
+	if probeName == "" {
+		probeName = "uptime"
+	}
+	probe, ok := idleProbes[probeName]
+	if !ok {
+		log.Printf("Idle reaper using uptime probe for %s, unknown probe %q", server.ID(), probeName)
+		probe = uptimeProbe{}
+	}
+
+	active, err := probe.Active(server, pods, state)
+	if err != nil {
+		log.Printf("Idle reaper %s probe failed for %s: %v", probeName, server.ID(), err)
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	var resumed []*discordgo.Message
+	if active {
+		state.LastActive = now
+		if state.Warned {
+			resumed = state.WarningMessages
+			state.Warned = false
+			state.WarningMessages = nil
+		}
+	}
+	idle := now.Sub(state.LastActive)
+	warn := !state.Warned && idle >= timeout-idleWarningLead && idle < timeout
+	if warn {
+		state.Warned = true
+	}
+	r.mu.Unlock()
+
+	if len(resumed) > 0 {
+		editIdleWarnings(s, resumed, fmt.Sprintf("✅ Activity detected on **%s**, shutdown cancelled", server.DisplayName))
+	}
+
+	if idle >= timeout {
+		log.Printf("Idle reaper stopping %s after %s idle", server.ID(), idle.Round(time.Second))
+		err := scaleGameServer(server, 0)
+		auditServerAction(s, server, "", "", "idle stop", err, config, db)
+		if err != nil {
+			log.Printf("Idle reaper failed to stop %s: %v", server.ID(), err)
+			return
+		}
+		recordServerUsage(db, server, "stop", "", "idle")
+
+		r.mu.Lock()
+		warnings := state.WarningMessages
+		delete(r.servers, server.ID())
+		r.mu.Unlock()
+
+		content := fmt.Sprintf("💤 Stopped **%s** after %s of inactivity", server.DisplayName, duration.HumanDuration(idle))
+		if len(warnings) > 0 {
+			editIdleWarnings(s, warnings, content)
+		} else {
+			announceToServerGuilds(s, config, server, &discordgo.MessageSend{Content: content})
+		}
+		return
+	}
+
+	if warn {
+		remaining := timeout - idle
+		messages := announceToServerGuilds(s, config, server, &discordgo.MessageSend{
+			Content: fmt.Sprintf("⏳ **%s** (%s) has been idle for %s and will be stopped in %s",
+				server.DisplayName, server.ID(), duration.HumanDuration(idle), duration.HumanDuration(remaining)),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Keep alive",
+							Style:    discordgo.PrimaryButton,
+							CustomID: serverCustomID("servers:keepalive:", server.ID()),
+						},
+					},
+				},
+			},
+		})
+
+		r.mu.Lock()
+		state.WarningMessages = messages
+		r.mu.Unlock()
+	}
+}
+
+// Reset the idle clock for a server, returning any outstanding warning messages
+func (r *idleReaper) keepAlive(serverID string) ([]*discordgo.Message, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.servers[serverID]
+	if !ok {
+		return nil, false
+	}
+	warnings := state.WarningMessages
+	state.LastActive = time.Now()
+	state.Warned = false
+	state.WarningMessages = nil
+	return warnings, true
+}
+
+func handleKeepAliveButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverID string, config *util.JuiceBotConfig, db *sql.DB) {
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: describeLookupError(i, serverID, err, "keep server alive"),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+
+	warnings, ok := reaper.keepAlive(server.ID())
+	if !ok {
+		content := fmt.Sprintf("Server **%s** is no longer scheduled to stop", server.DisplayName)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    content,
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		return
+	}
+
+	log.Printf("User %s in guild %s kept %s alive", i.Member.User.ID, i.GuildID, server.ID())
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, "keep alive", nil, config, db)
+	content := fmt.Sprintf("⏳ **%s** was kept alive by <@%s>", server.DisplayName, i.Member.User.ID)
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Parse: []discordgo.AllowedMentionType{},
+			},
+		},
+	})
+
+	// The warning may have been posted to other guilds too
+	var others []*discordgo.Message
+	for _, message := range warnings {
+		if message.ID != i.Message.ID {
+			others = append(others, message)
+		}
+	}
+	editIdleWarnings(s, others, content)
+}
+
+// Replace the content of warning messages and drop their buttons
+func editIdleWarnings(s *discordgo.Session, messages []*discordgo.Message, content string) {
+	for _, message := range messages {
+		_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:         message.ID,
+			Channel:    message.ChannelID,
+			Content:    &content,
+			Components: []discordgo.MessageComponent{},
+		})
+		if err != nil {
+			log.Printf("Failed to edit idle warning %s in channel %s: %v", message.ID, message.ChannelID, err)
+		}
+	}
+}
+
+// When a server was last started, from its recorded usage so replaced pods don't reset
+// the clock, or from its pods for starts that weren't recorded. Reports the current
+// time and false if neither knows.
+func serverStartTime(server *gameServer, pods []corev1.Pod, db *sql.DB) (time.Time, bool) {
+	startedAt, ok, err := util.GetServerStartedAt(db, server.ID())
+	if err != nil {
+		log.Printf("Idle reaper failed to get recorded start of %s: %v", server.ID(), err)
+	}
+	if ok {
+		return startedAt, true
+	}
+	return podStartTime(pods)
+}
+
+// The earliest pod start time, so uptime survives bot restarts. Reports
+// the current time and false if no pod has started yet.
+func podStartTime(pods []corev1.Pod) (time.Time, bool) {
+	start := time.Now()
+	started := false
+	for _, pod := range pods {
+		if pod.Status.StartTime != nil && pod.Status.StartTime.Time.Before(start) {
+			start = pod.Status.StartTime.Time
+			started = true
+		}
+	}
+	return start, started
+}
+
+// uptimeProbe never sees activity, so the timeout acts as a maximum uptime
+type uptimeProbe struct{}
+
+func (uptimeProbe) Active(server *gameServer, pods []corev1.Pod, state *idleState) (bool, error) {
+	return false, nil
+}
+
+// playersProbe treats a server as active while anyone is online
+type playersProbe struct{}
+
+func (playersProbe) Active(server *gameServer, pods []corev1.Pod, state *idleState) (bool, error) {
+	if readyPod(pods) == nil {
+		// Still booting, don't count it against the timeout
+		return true, nil
+	}
+
+	// Like /servers players, the query annotation says how to ask
+	protocol, ok := server.Annotation("query")
+	if !ok {
+		return false, fmt.Errorf("%w, set the %s annotation", errNoQueryProtocol, server.AnnotationKey("query"))
+	}
+
+	info, err := queryPodPlayers(server, protocol, pods, idleQueryTimeout)
+	if err != nil {
+		return false, err
+	}
+	return info.Online > 0, nil
+}
+
+// networkProbe treats a server as active while its pods move enough traffic
+type networkProbe struct{}
+
+func (networkProbe) Active(server *gameServer, pods []corev1.Pod, state *idleState) (bool, error) {
+	threshold := uint64(idleDefaultNetworkBytes)
+	if value, ok := server.Annotation("idle-network-bytes"); ok {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid network threshold %q: %v", value, err)
+		}
+		threshold = parsed
+	}
+
+	var total uint64
+	found := false
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.Spec.NodeName == "" {
+			continue
+		}
+		bytes, err := podNetworkBytes(server.cluster(), &pod)
+		if err != nil {
+			return false, err
+		}
+		total += bytes
+		found = true
+	}
+	if !found {
+		return true, nil
+	}
+
+	previous, hadSample := state.NetworkBytes, state.HasNetworkSample
+	state.NetworkBytes, state.HasNetworkSample = total, true
+
+	// The first sample has nothing to compare against, and counters reset when pods restart
+	if !hadSample || total < previous {
+		return true, nil
+	}
+	return total-previous >= threshold, nil
+}
+
+// Read a pod's received and transmitted bytes from the kubelet summary API
+func podNetworkBytes(cluster *kubernetesBackend, pod *corev1.Pod) (uint64, error) {
+	raw, err := cluster.client.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(pod.Spec.NodeName).
+		SubResource("proxy").
+		Suffix("stats/summary").
+		DoRaw(context.TODO())
+	if err != nil {
+		return 0, err
+	}
+
+	var summary struct {
+		Pods []struct {
+			PodRef struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"podRef"`
+			Network *struct {
+				RxBytes *uint64 `json:"rxBytes"`
+				TxBytes *uint64 `json:"txBytes"`
+			} `json:"network"`
+		} `json:"pods"`
+	}
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		return 0, fmt.Errorf("failed to parse stats summary: %v", err)
+	}
+
+	for _, stats := range summary.Pods {
+		if stats.PodRef.Name != pod.Name || stats.PodRef.Namespace != pod.Namespace || stats.Network == nil {
+			continue
+		}
+		var total uint64
+		if stats.Network.RxBytes != nil {
+			total += *stats.Network.RxBytes
+		}
+		if stats.Network.TxBytes != nil {
+			total += *stats.Network.TxBytes
+		}
+		return total, nil
+	}
+	return 0, fmt.Errorf("no network stats for pod %s/%s", pod.Namespace, pod.Name)
+}
+
+// Find a running pod that has passed its readiness checks
+func readyPod(pods []corev1.Pod) *corev1.Pod {
+	for idx := range pods {
+		pod := &pods[idx]
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				return pod
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	logsDefaultLines = 200
+	logsMaxLines     = 5000
+	logsMaxMinutes   = 24 * 60
+	// Keep attachments well under Discord's upload limit
+	logsMaxBytes = 4 * 1024 * 1024
+)
+
+var logsMinValue = 1.0
+
+func handleServerLogs(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig) {
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
+	for _, opt := range options {
+		optionMap[opt.Name] = opt
+	}
+
+	serverOpt, ok := optionMap["server"]
+	if !ok {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Please specify a server ID to fetch logs from (format: namespace/name)",
+			},
+		})
+		return
+	}
+	serverID := serverOpt.StringValue()
+
+	logOptions := &corev1.PodLogOptions{
+		Timestamps: true,
+		LimitBytes: int64Ptr(logsMaxBytes),
+	}
+	if opt, ok := optionMap["minutes"]; ok {
+		logOptions.SinceSeconds = int64Ptr(opt.IntValue() * 60)
+	}
+	if opt, ok := optionMap["lines"]; ok {
+		logOptions.TailLines = int64Ptr(opt.IntValue())
+	} else if logOptions.SinceSeconds == nil {
+		logOptions.TailLines = int64Ptr(logsDefaultLines)
+	}
+
+	// Streaming logs can take a while, so defer the response
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "retrieve server logs")
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	if !server.onKubernetes() {
+		content := fmt.Sprintf("❌ Server **%s** is a %s, which doesn't keep logs", server.DisplayName, strings.ToLower(server.Kind))
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	pods, err := listServerPods(server)
+	if err != nil {
+		log.Printf("Failed to list pods for %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		content := "❌ Unable to retrieve server logs"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+	if len(pods) == 0 {
+		content := fmt.Sprintf("❌ Server **%s** has no running pods", server.DisplayName)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	// Use the newest pod, which is the one a restart or rollout would have produced
+	pod := pods[len(pods)-1]
+	logOptions.Container = pod.Spec.Containers[0].Name
+
+	logs, err := fetchPodLogs(server.cluster(), pod.Namespace, pod.Name, logOptions)
+	if err != nil {
+		log.Printf("Failed to fetch logs for pod %s/%s for user %s in guild %s: %v", pod.Namespace, pod.Name, i.Member.User.ID, i.GuildID, err)
+		content := "❌ Unable to retrieve server logs"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	logs, redacted := redactLogLines(logs, config.Servers.LogRedactPatterns)
+
+	content := fmt.Sprintf("📄 Logs for **%s** from pod `%s` container `%s`", server.DisplayName, pod.Name, logOptions.Container)
+	if redacted > 0 {
+		content += fmt.Sprintf(" (%d lines redacted)", redacted)
+	}
+	if len(logs) == 0 {
+		content += "\nNo log output in the requested range"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	log.Printf("User %s in guild %s fetched logs for %s", i.Member.User.ID, i.GuildID, server.ID())
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		Files: []*discordgo.File{
+			{
+				Name:        fmt.Sprintf("%s-%s.log", server.Name, time.Now().Format("20060102-150405")),
+				ContentType: "text/plain",
+				Reader:      bytes.NewReader(logs),
+			},
+		},
+	})
+}
+
+// Stream a pod's container logs through the GetLogs API
+func fetchPodLogs(cluster *kubernetesBackend, namespace string, podName string, logOptions *corev1.PodLogOptions) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stream, err := cluster.client.CoreV1().Pods(namespace).GetLogs(podName, logOptions).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(stream); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Replace every line matching one of the configured patterns, returning how many were replaced
+func redactLogLines(logs []byte, patterns []string) ([]byte, int) {
+	var matchers []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Ignoring invalid log redaction pattern %q: %v", pattern, err)
+			continue
+		}
+		matchers = append(matchers, re)
+	}
+	if len(matchers) == 0 {
+		return logs, 0
+	}
+
+	// Split by hand rather than with a bufio.Scanner, whose line length limit would
+	// drop everything after a long line
+	var out bytes.Buffer
+	redacted := 0
+	for len(logs) > 0 {
+		var line []byte
+		line, logs, _ = bytes.Cut(logs, []byte("\n"))
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		for _, re := range matchers {
+			if re.Match(line) {
+				line = []byte("[redacted]")
+				redacted++
+				break
+			}
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), redacted
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactLogLines(t *testing.T) {
+	long := strings.Repeat("x", 128*1024)
+	tests := []struct {
+		logs     string
+		want     string
+		redacted int
+	}{
+		{logs: "", want: "", redacted: 0},
+		{logs: "joined\npassword=hunter2\nleft\n", want: "joined\n[redacted]\nleft\n", redacted: 1},
+		{logs: "password=a\r\nok", want: "[redacted]\nok\n", redacted: 1},
+		{logs: long + "\npassword=hunter2\nleft", want: long + "\n[redacted]\nleft\n", redacted: 1},
+	}
+
+	for _, tt := range tests {
+		got, redacted := redactLogLines([]byte(tt.logs), []string{"password=", "("})
+		if string(got) != tt.want || redacted != tt.redacted {
+			t.Errorf("redactLogLines(%.40q) = %.40q, %d, want %.40q, %d", tt.logs, got, redacted, tt.want, tt.redacted)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// PodMetrics from metrics-server, read through the dynamic client to avoid depending on k8s.io/metrics
+var podMetricsResource = schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+
+// resourceUsage is current usage next to what was asked for
+type resourceUsage struct {
+	CPU         resource.Quantity
+	Memory      resource.Quantity
+	CPURequest  resource.Quantity
+	CPULimit    resource.Quantity
+	MemRequest  resource.Quantity
+	MemLimit    resource.Quantity
+	HasSnapshot bool
+}
+
+// Fetch current CPU and memory usage for a server's pods, keyed by pod name
+func listPodUsage(server *gameServer, pods []corev1.Pod) (map[string]*resourceUsage, error) {
+	usage := map[string]*resourceUsage{}
+	if len(pods) == 0 {
+		return usage, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(server.Selector)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := server.cluster().dynamic.Resource(podMetricsResource).Namespace(server.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics: %v", err)
+	}
+
+	for _, pod := range pods {
+		podUsage := &resourceUsage{}
+		for _, container := range pod.Spec.Containers {
+			addQuantity(&podUsage.CPURequest, container.Resources.Requests, corev1.ResourceCPU)
+			addQuantity(&podUsage.CPULimit, container.Resources.Limits, corev1.ResourceCPU)
+			addQuantity(&podUsage.MemRequest, container.Resources.Requests, corev1.ResourceMemory)
+			addQuantity(&podUsage.MemLimit, container.Resources.Limits, corev1.ResourceMemory)
+		}
+		usage[pod.Name] = podUsage
+	}
+
+	for _, item := range metrics.Items {
+		podUsage, ok := usage[item.GetName()]
+		if !ok {
+			continue
+		}
+		containers, _, _ := unstructured.NestedSlice(item.Object, "containers")
+		for _, container := range containers {
+			containerUsage, ok := container.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			values, _, _ := unstructured.NestedStringMap(containerUsage, "usage")
+			if cpu, err := resource.ParseQuantity(values["cpu"]); err == nil {
+				podUsage.CPU.Add(cpu)
+			}
+			if memory, err := resource.ParseQuantity(values["memory"]); err == nil {
+				podUsage.Memory.Add(memory)
+			}
+		}
+		podUsage.HasSnapshot = true
+	}
+	return usage, nil
+}
+
+// Current usage of all of a server's pods together
+func serverUsage(server *gameServer) (*resourceUsage, error) {
+	pods, err := listServerPods(server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+	usage, err := listPodUsage(server, pods)
+	if err != nil {
+		return nil, err
+	}
+	return totalUsage(usage), nil
+}
+
+// Add up usage across all of a server's pods
+func totalUsage(usage map[string]*resourceUsage) *resourceUsage {
+	total := &resourceUsage{}
+	for _, podUsage := range usage {
+		if !podUsage.HasSnapshot {
+			continue
+		}
+		total.CPU.Add(podUsage.CPU)
+		total.Memory.Add(podUsage.Memory)
+		total.CPURequest.Add(podUsage.CPURequest)
+		total.CPULimit.Add(podUsage.CPULimit)
+		total.MemRequest.Add(podUsage.MemRequest)
+		total.MemLimit.Add(podUsage.MemLimit)
+		total.HasSnapshot = true
+	}
+	return total
+}
+
+func addQuantity(total *resource.Quantity, list corev1.ResourceList, name corev1.ResourceName) {
+	if quantity, ok := list[name]; ok {
+		total.Add(quantity)
+	}
+}
+
+// Render usage as e.g. "CPU 250m / 1.00 cores limit (25%), Memory 1.2Gi / 2.0Gi limit (60%)"
+func describeUsage(usage *resourceUsage) string {
+	if !usage.HasSnapshot {
+		return "no metrics yet"
+	}
+	cpu := describeQuantityUsage("CPU", float64(usage.CPU.MilliValue()), float64(usage.CPURequest.MilliValue()), float64(usage.CPULimit.MilliValue()), formatCPU)
+	memory := describeQuantityUsage("Memory", float64(usage.Memory.Value()), float64(usage.MemRequest.Value()), float64(usage.MemLimit.Value()), formatMemory)
+	return cpu + ", " + memory
+}
+
+// Compare against the limit, which is what gets a pod throttled or OOM killed, falling back to the request
+func describeQuantityUsage(name string, used float64, request float64, limit float64, format func(float64) string) string {
+	content := fmt.Sprintf("%s %s", name, format(used))
+	switch {
+	case limit > 0:
+		content += fmt.Sprintf(" / %s limit (%.0f%%)", format(limit), used/limit*100)
+	case request > 0:
+		content += fmt.Sprintf(" / %s request (%.0f%%)", format(request), used/request*100)
+	}
+	return content
+}
+
+func formatCPU(millicores float64) string {
+	if millicores >= 1000 {
+		return fmt.Sprintf("%.2f cores", millicores/1000)
+	}
+	return fmt.Sprintf("%.0fm", millicores)
+}
+
+func formatMemory(bytes float64) string {
+	if bytes >= 1<<30 {
+		return fmt.Sprintf("%.1fGi", bytes/(1<<30))
+	}
+	return fmt.Sprintf("%.0fMi", bytes/(1<<20))
+}
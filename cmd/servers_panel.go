@@ -0,0 +1,382 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+)
+
+// Each server gets its own message, so keep a guild with lots of servers from flooding the channel
+const panelMaxServers = 10
+
+// Post a control panel message for each of the guild's servers, or just the one asked for
+func handleServerPanel(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig) {
+	var serverID string
+	for _, opt := range options {
+		if opt.Name == "server" {
+			serverID = opt.StringValue()
+		}
+	}
+
+	// Player queries can take a couple of seconds, so defer the response
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	respond := func(content string) {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+	}
+
+	var servers []*gameServer
+	var listErr error
+	if serverID != "" {
+		server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+		if err != nil {
+			content := describeLookupError(i, serverID, err, "show server panel")
+			respond(content)
+			return
+		}
+		servers = []*gameServer{server}
+	} else {
+		servers, listErr = listGameServers(config, i.GuildID)
+		if listErr != nil {
+			log.Printf("Failed to list game servers for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, listErr)
+		}
+		if listErr != nil && len(servers) == 0 {
+			respond("❌ Unable to retrieve game servers")
+			return
+		}
+		if len(servers) == 0 {
+			respond(fmt.Sprintf("No game servers found for this guild. Make sure deployments/statefulsets have the label `%s` and annotation `%sguilds` containing this guild ID (%s)",
+				config.Servers.LabelSelector, config.Servers.AnnotationPrefix, i.GuildID))
+			return
+		}
+	}
+
+	var notes []string
+	if len(servers) > panelMaxServers {
+		notes = append(notes, fmt.Sprintf("Showing %d of %d servers, use `/servers panel server:` for the others", panelMaxServers, len(servers)))
+		servers = servers[:panelMaxServers]
+	}
+	// Servers in clusters that couldn't be reached are missing from the panel
+	if listErr != nil {
+		notes = append(notes, "⚠️ Some servers couldn't be listed, a cluster may be unreachable")
+	}
+
+	players := lookupAllServerPlayers(servers)
+	for idx, server := range servers {
+		embeds := []*discordgo.MessageEmbed{buildPanelEmbed(config, server, players[idx])}
+		components := panelComponents(server)
+		// The first server takes the deferred response, the rest get follow-up messages
+		if idx == 0 {
+			content := strings.Join(notes, "\n")
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content:    &content,
+				Embeds:     &embeds,
+				Components: &components,
+			})
+			continue
+		}
+		_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Embeds:     embeds,
+			Components: components,
+		})
+		if err != nil {
+			log.Printf("Failed to post panel for %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		}
+	}
+}
+
+// Render a server's panel, with any extra fields below its state
+func buildPanelEmbed(config *util.JuiceBotConfig, server *gameServer, players *playerInfo, fields ...*discordgo.MessageEmbedField) *discordgo.MessageEmbed {
+	statusEmoji := "🔴"
+	state := server.State()
+	color := 0xe74c3c
+	switch {
+	case server.Replicas == 0 && server.ReadyReplicas > 0:
+		// Scaled down but the old pod hasn't gone yet
+		statusEmoji = "🟠"
+		state = "stopping"
+		color = 0xe67e22
+	case state == "running":
+		statusEmoji = "🟢"
+		color = 0x2ecc71
+	case state == "starting":
+		statusEmoji = "🟡"
+		color = 0xf1c40f
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       server.DisplayName,
+		Description: fmt.Sprintf("%s `%s`", server.Kind, server.ID()),
+		Color:       color,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "State",
+				Value:  fmt.Sprintf("%s %s (%d/%d replicas)", statusEmoji, state, server.ReadyReplicas, server.Replicas),
+				Inline: true,
+			},
+		},
+	}
+
+	if players != nil {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Players",
+			Value:  truncateField(describePlayers(players, playersListMaxNames)),
+			Inline: true,
+		})
+	}
+
+	if server.ReadyReplicas > 0 && server.Replicas > 0 {
+		var addresses []string
+		if server.onKubernetes() {
+			addresses = connectAddresses(config, server)
+		} else {
+			addresses = serverConnectAddresses(config, server, nil)
+		}
+		if len(addresses) > 0 {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:  "Connect",
+				Value: truncateField(strings.Join(addresses, ", ")),
+			})
+		}
+	}
+
+	embed.Fields = append(embed.Fields, fields...)
+	return embed
+}
+
+// Buttons for a server's panel, with the ones that don't apply to its state disabled
+func panelComponents(server *gameServer) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Start",
+					Style:    discordgo.SuccessButton,
+					CustomID: serverCustomID("servers:panel:start:", server.ID()),
+					Disabled: server.Replicas > 0,
+				},
+				discordgo.Button{
+					Label:    "Stop",
+					Style:    discordgo.DangerButton,
+					CustomID: serverCustomID("servers:panel:stop:", server.ID()),
+					Disabled: server.Replicas == 0,
+				},
+				discordgo.Button{
+					Label:    "Restart",
+					Style:    discordgo.PrimaryButton,
+					CustomID: serverCustomID("servers:panel:restart:", server.ID()),
+					// Only Deployments and StatefulSets can be restarted
+					Disabled: server.Replicas == 0 || (server.Kind != "Deployment" && server.Kind != "StatefulSet"),
+				},
+				discordgo.Button{
+					Label:    "Refresh",
+					Style:    discordgo.SecondaryButton,
+					CustomID: serverCustomID("servers:panel:refresh:", server.ID()),
+				},
+			},
+		},
+	}
+}
+
+// Look a server up again and redraw its panel on a deferred component response
+func updatePanel(s *discordgo.Session, i *discordgo.InteractionCreate, serverID string, config *util.JuiceBotConfig, fields ...*discordgo.MessageEmbedField) {
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		log.Printf("Failed to look up server %s to update its panel for user %s in guild %s: %v", serverID, i.Member.User.ID, i.GuildID, err)
+		content := fmt.Sprintf("❌ Server **%s** is no longer available", serverID)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Embeds:     &[]*discordgo.MessageEmbed{},
+			Components: &[]discordgo.MessageComponent{},
+		})
+		return
+	}
+
+	var players *playerInfo
+	if server.ReadyReplicas > 0 {
+		if _, ok := server.Annotation("query"); ok {
+			players, err = lookupServerPlayers(server, playersQueryTimeout)
+			if err != nil {
+				log.Printf("Failed to query players on %s: %v", server.ID(), err)
+			}
+		}
+	}
+
+	embeds := []*discordgo.MessageEmbed{buildPanelEmbed(config, server, players, fields...)}
+	components := panelComponents(server)
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &embeds,
+		Components: &components,
+	})
+}
+
+// Handle a panel button, custom IDs look like servers:panel:<action>:<server id>. Start, stop
+// and restart get the same role checks as the slash subcommands.
+func handlePanelButton(s *discordgo.Session, i *discordgo.InteractionCreate, value string, config *util.JuiceBotConfig, db *sql.DB) {
+	action, serverID, ok := strings.Cut(value, ":")
+	if !ok {
+		return
+	}
+	serverID = resolveButtonServerID(config, i.GuildID, serverID)
+
+	respondEphemeral := func(content string, components []discordgo.MessageComponent) {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content:    content,
+				Components: components,
+				Flags:      discordgo.MessageFlagsEphemeral,
+				AllowedMentions: &discordgo.MessageAllowedMentions{
+					Parse: []discordgo.AllowedMentionType{},
+				},
+			},
+		})
+	}
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		respondEphemeral(describeLookupError(i, serverID, err, action+" server"), nil)
+		return
+	}
+
+	if action != "refresh" && !checkServerRoles(s, i, server, action, config, db, ephemeralReply(s, i)) {
+		return
+	}
+
+	switch action {
+	case "start":
+		if server.Replicas > 0 {
+			respondEphemeral(fmt.Sprintf("❌ Server **%s** is already running!", server.DisplayName), nil)
+			return
+		}
+		if required := startVotesRequired(server); required > 1 {
+			respondEphemeral(fmt.Sprintf("❌ Server **%s** needs %d votes to start, use `/servers start` to open a vote", server.DisplayName, required), nil)
+			return
+		}
+		if !enforceGuildQuota(s, i, server, config, db, respondEphemeral) {
+			return
+		}
+	case "stop":
+		if server.Replicas == 0 {
+			respondEphemeral(fmt.Sprintf("❌ Server **%s** is already stopped!", server.DisplayName), nil)
+			return
+		}
+	case "restart":
+		if server.Replicas == 0 {
+			respondEphemeral(fmt.Sprintf("❌ Server **%s** is not running, use Start instead", server.DisplayName), nil)
+			return
+		}
+	case "refresh":
+	default:
+		return
+	}
+
+	// Everything from here edits the panel in place
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	resultField := func(value string) *discordgo.MessageEmbedField {
+		return &discordgo.MessageEmbedField{
+			Name:  "Last Action",
+			Value: truncateField(value),
+		}
+	}
+
+	startedAt := time.Now()
+	switch action {
+	case "refresh":
+		updatePanel(s, i, server.ID(), config)
+		return
+	case "start":
+		err = server.backend.Start(server)
+	case "stop":
+		err = server.backend.Stop(server)
+	case "restart":
+		err = restartGameServer(server)
+	}
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, action, err, config, db)
+	if err != nil {
+		content := fmt.Sprintf("❌ Unable to %s server", action)
+		if errors.Is(err, errRestartUnsupported) {
+			content = fmt.Sprintf("❌ Server is a %s, which can't be restarted", server.Kind)
+		} else {
+			log.Printf("Failed to %s %s %s for user %s in guild %s: %v", action, strings.ToLower(server.Kind), server.ID(), i.Member.User.ID, i.GuildID, err)
+		}
+		updatePanel(s, i, server.ID(), config, resultField(content))
+		return
+	}
+
+	log.Printf("User %s in guild %s used the panel to %s %s", i.Member.User.ID, i.GuildID, action, server.ID())
+	var result string
+	switch action {
+	case "start":
+		recordServerUsage(db, server, "start", i.Member.User.ID, "command")
+		result = fmt.Sprintf("🟢 Started by <@%s>", i.Member.User.ID)
+	case "stop":
+		recordServerUsage(db, server, "stop", i.Member.User.ID, "command")
+		result = fmt.Sprintf("🔴 Stopped by <@%s>", i.Member.User.ID)
+	case "restart":
+		result = fmt.Sprintf("🔄 Restarted by <@%s>", i.Member.User.ID)
+	}
+	updatePanel(s, i, server.ID(), config, resultField(result))
+
+	// Only Kubernetes servers have pods to follow
+	if server.onKubernetes() {
+		go followPanel(s, i, server, action, startedAt, result, config)
+	}
+}
+
+// Keep redrawing a panel until a start or restart finishes rolling out, or a stop takes the last pod down
+func followPanel(s *discordgo.Session, i *discordgo.InteractionCreate, server *gameServer, action string, since time.Time, result string, config *util.JuiceBotConfig) {
+	resultField := &discordgo.MessageEmbedField{
+		Name:  "Last Action",
+		Value: result,
+	}
+
+	if action == "stop" {
+		deadline := time.Now().Add(rolloutTimeout(server))
+		for time.Now().Before(deadline) {
+			time.Sleep(rolloutPollInterval)
+			current, err := findGameServer(config, server.ID(), i.GuildID, i.Member.User.ID)
+			if err != nil || current.ReadyReplicas == 0 {
+				break
+			}
+		}
+		updatePanel(s, i, server.ID(), config, resultField)
+		return
+	}
+
+	// Pods created slightly before the API call returned still belong to this rollout
+	progress, err := waitForRollout(server, since.Add(-5*time.Second), rolloutTimeout(server), func(progress rolloutProgress) {
+		updatePanel(s, i, server.ID(), config, resultField, &discordgo.MessageEmbedField{
+			Name:  "Progress",
+			Value: describeRollout(progress),
+		})
+	})
+
+	switch {
+	case err != nil:
+		log.Printf("Server %s did not become ready after %s for user %s in guild %s: %v", server.ID(), action, i.Member.User.ID, i.GuildID, err)
+		resultField.Value += fmt.Sprintf("\n⌛ Did not become ready: %v", err)
+	case progress.Failed:
+		log.Printf("Server %s failed to %s for user %s in guild %s: %s", server.ID(), action, i.Member.User.ID, i.GuildID, progress.Reason)
+		resultField.Value += fmt.Sprintf("\n❌ Failed to %s", action)
+		if progress.Reason != "" {
+			resultField.Value += ": " + progress.Reason
+		}
+	default:
+		resultField.Value += fmt.Sprintf("\n✅ Ready after %s", time.Since(since).Round(time.Second))
+	}
+	resultField.Value = truncateField(resultField.Value)
+	updatePanel(s, i, server.ID(), config, resultField)
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// Kept short so one dead server doesn't hold up the whole list
+	playersQueryTimeout = 2 * time.Second
+	// Names shown per server in /servers list and /servers players
+	playersListMaxNames    = 10
+	playersCommandMaxNames = 50
+)
+
+var (
+	errNoQueryProtocol = errors.New("no query protocol")
+	errNoReadyPod      = errors.New("no ready pod")
+)
+
+// Ask a server's ready pod who is online, using the given protocol and the query-port annotation
+func queryPodPlayers(server *gameServer, protocolName string, pods []corev1.Pod, timeout time.Duration) (*playerInfo, error) {
+	protocol, ok := queryProtocols[protocolName]
+	if !ok {
+		return nil, fmt.Errorf("unknown query protocol %q", protocolName)
+	}
+
+	pod := readyPod(pods)
+	if pod == nil {
+		return nil, errNoReadyPod
+	}
+
+	port := strconv.Itoa(protocol.DefaultPort)
+	if value, ok := server.Annotation("query-port"); ok {
+		port = value
+	}
+	return protocol.Query(net.JoinHostPort(pod.Status.PodIP, port), timeout)
+}
+
+// Look up who is online on a server that has a query annotation
+func lookupServerPlayers(server *gameServer, timeout time.Duration) (*playerInfo, error) {
+	protocol, ok := server.Annotation("query")
+	if !ok || !server.onKubernetes() {
+		return nil, errNoQueryProtocol
+	}
+
+	pods, err := listServerPods(server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+	return queryPodPlayers(server, protocol, pods, timeout)
+}
+
+// Look up players on every running server at once, so the slowest query bounds the wait
+func lookupAllServerPlayers(servers []*gameServer) []*playerInfo {
+	players := make([]*playerInfo, len(servers))
+	done := make(chan struct{})
+	pending := 0
+	for idx, server := range servers {
+		if server.ReadyReplicas == 0 {
+			continue
+		}
+		if _, ok := server.Annotation("query"); !ok {
+			continue
+		}
+		pending++
+		go func() {
+			defer func() { done <- struct{}{} }()
+			info, err := lookupServerPlayers(server, playersQueryTimeout)
+			if err != nil {
+				log.Printf("Failed to query players on %s: %v", server.ID(), err)
+				return
+			}
+			players[idx] = info
+		}()
+	}
+	for range pending {
+		<-done
+	}
+	return players
+}
+
+// Render online/max players followed by up to maxNames names
+func describePlayers(info *playerInfo, maxNames int) string {
+	content := fmt.Sprintf("👥 %d/%d", info.Online, info.Max)
+	if len(info.Names) == 0 {
+		return content
+	}
+
+	names := info.Names
+	if len(names) > maxNames {
+		names = names[:maxNames]
+	}
+	content += ": " + strings.Join(names, ", ")
+	// Minecraft only samples some of the online players
+	if hidden := info.Online - len(names); hidden > 0 {
+		content += fmt.Sprintf(" and %d more", hidden)
+	}
+	return content
+}
+
+func handleServerPlayers(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig) {
+	if len(options) == 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Please specify a server ID to query (format: namespace/name)",
+			},
+		})
+		return
+	}
+
+	serverID := options[0].StringValue()
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "query players")
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	if server.ReadyReplicas == 0 {
+		content := fmt.Sprintf("🔴 Server **%s** is not running", server.DisplayName)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	info, err := lookupServerPlayers(server, playersQueryTimeout)
+	var content string
+	switch {
+	case errors.Is(err, errNoQueryProtocol):
+		content = fmt.Sprintf("❌ Server **%s** doesn't say how to query it, set the `%s` annotation to one of: minecraft, a2s", server.DisplayName, server.AnnotationKey("query"))
+	case err != nil:
+		log.Printf("Failed to query players on %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		content = fmt.Sprintf("❌ Server **%s** didn't answer the player query", server.DisplayName)
+	case info.Online == 0:
+		content = fmt.Sprintf("👥 Nobody is on **%s** (0/%d)", server.DisplayName, info.Max)
+	default:
+		content = fmt.Sprintf("**%s** %s", server.DisplayName, describePlayers(info, playersCommandMaxNames))
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+}
@@ -0,0 +1,340 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+const (
+	// Process servers are addressed as local/<name>
+	processNamespace = "local"
+	// How long a process gets to exit after the stop signal before it is killed
+	processStopTimeout = 30 * time.Second
+)
+
+var processStopSignals = map[string]syscall.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+// Environment variables holding the bot's own secrets, never passed on to process servers
+var processHiddenEnv = []string{"TOKEN", "POSTGRES_URI"}
+
+// processDefinition is a process server from config
+type processDefinition struct {
+	Command    []string
+	WorkingDir string
+	StopSignal string
+}
+
+// localProcess is a running process server
+type localProcess struct {
+	cmd       *exec.Cmd
+	startedAt time.Time
+	stopping  bool
+	exited    chan struct{}
+}
+
+// processExit is how a process server last ended
+type processExit struct {
+	At  time.Time
+	Err error
+}
+
+// processBackend runs game servers as child processes of the bot
+type processBackend struct {
+	mu          sync.Mutex
+	definitions map[string]processDefinition
+	running     map[string]*localProcess
+	exits       map[string]processExit
+	// Where processes that exit on their own record their stop, set by TrackLocalProcessUsage
+	usageDB *sql.DB
+}
+
+var localProcesses = &processBackend{
+	definitions: map[string]processDefinition{},
+	running:     map[string]*localProcess{},
+	exits:       map[string]processExit{},
+}
+
+func (b *processBackend) List(config *util.JuiceBotConfig, guildID string) ([]*gameServer, error) {
+	var servers []*gameServer
+	for idx := range config.Servers.Processes {
+		if slices.Contains(config.Servers.Processes[idx].Guilds, guildID) {
+			servers = append(servers, b.gameServer(config, idx))
+		}
+	}
+	return servers, nil
+}
+
+func (b *processBackend) Get(config *util.JuiceBotConfig, serverID string, guildID string, userID string) (*gameServer, error) {
+	namespace, name, ok := strings.Cut(serverID, "/")
+	if !ok {
+		return nil, errServerIDFormat
+	}
+	// Cluster qualified IDs have another part
+	if namespace != processNamespace || strings.Contains(name, "/") {
+		return nil, errServerNotFound
+	}
+
+	for idx, process := range config.Servers.Processes {
+		if process.Name != name {
+			continue
+		}
+		if !slices.Contains(process.Guilds, guildID) {
+			log.Printf("User %s in guild %s attempted to access process %s belonging to guilds %v", userID, guildID, name, process.Guilds)
+			return nil, errServerNotFound
+		}
+		return b.gameServer(config, idx), nil
+	}
+	return nil, errServerNotFound
+}
+
+// Build a game server from a process definition, remembering the definition for Start
+func (b *processBackend) gameServer(config *util.JuiceBotConfig, idx int) *gameServer {
+	process := config.Servers.Processes[idx]
+
+	server := &gameServer{
+		Kind:             "Process",
+		Namespace:        processNamespace,
+		Name:             process.Name,
+		DisplayName:      process.DisplayName,
+		Annotations:      map[string]string{},
+		annotationPrefix: config.Servers.AnnotationPrefix,
+		backend:          b,
+	}
+	if server.DisplayName == "" {
+		server.DisplayName = process.Name
+	}
+	// Guilds and connect addresses are read from annotations on cluster servers, so mirror them here
+	server.Annotations[server.AnnotationKey("guilds")] = strings.Join(process.Guilds, ",")
+	if process.Connect != "" {
+		server.Annotations[server.AnnotationKey("connect")] = process.Connect
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.definitions[process.Name] = processDefinition{
+		Command:    process.Command,
+		WorkingDir: process.WorkingDir,
+		StopSignal: process.StopSignal,
+	}
+	if running, ok := b.running[process.Name]; ok {
+		server.ReadyReplicas = 1
+		if !running.stopping {
+			server.Replicas = 1
+		}
+	}
+	return server
+}
+
+func (b *processBackend) Start(server *gameServer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.running[server.Name]; ok {
+		return fmt.Errorf("process %s is already running", server.Name)
+	}
+	definition, ok := b.definitions[server.Name]
+	if !ok || len(definition.Command) == 0 {
+		return fmt.Errorf("process %s has no command", server.Name)
+	}
+
+	cmd := exec.Command(definition.Command[0], definition.Command[1:]...)
+	cmd.Dir = definition.WorkingDir
+	cmd.Env = processEnv()
+	// A process group of its own keeps terminal signals meant for the bot away from
+	// the server, and lets stop reach anything a wrapper script started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %v", server.Name, err)
+	}
+
+	process := &localProcess{
+		cmd:       cmd,
+		startedAt: time.Now(),
+		exited:    make(chan struct{}),
+	}
+	b.running[server.Name] = process
+
+	go func() {
+		err := cmd.Wait()
+		close(process.exited)
+
+		b.mu.Lock()
+		delete(b.running, server.Name)
+		b.exits[server.Name] = processExit{At: time.Now(), Err: err}
+		stopping := process.stopping
+		db := b.usageDB
+		b.mu.Unlock()
+
+		// Stops asked of the bot are recorded where they were asked for
+		if stopping {
+			return
+		}
+		if err != nil {
+			log.Printf("Process %s exited unexpectedly: %v", server.Name, err)
+		} else {
+			log.Printf("Process %s exited on its own", server.Name)
+		}
+		if db != nil {
+			recordServerUsage(db, server, "stop", "", "exit")
+		}
+	}()
+	return nil
+}
+
+// Send the stop signal, then kill the process if it hasn't exited in time
+func (b *processBackend) Stop(server *gameServer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	process, ok := b.running[server.Name]
+	if !ok {
+		return fmt.Errorf("process %s is not running", server.Name)
+	}
+
+	signalName := b.definitions[server.Name].StopSignal
+	if signalName == "" {
+		signalName = "SIGTERM"
+	}
+	signal, ok := processStopSignals[strings.ToUpper(signalName)]
+	if !ok {
+		return fmt.Errorf("unknown stop signal %s", signalName)
+	}
+
+	if err := syscall.Kill(-process.cmd.Process.Pid, signal); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("failed to signal %s: %v", server.Name, err)
+	}
+	process.stopping = true
+
+	go func() {
+		select {
+		case <-process.exited:
+		case <-time.After(processStopTimeout):
+			log.Printf("Process %s did not exit within %s of %s, killing it", server.Name, processStopTimeout, signalName)
+			syscall.Kill(-process.cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}()
+	return nil
+}
+
+// Record a usage stop for process servers that exit without being asked to
+func TrackLocalProcessUsage(db *sql.DB) {
+	localProcesses.mu.Lock()
+	defer localProcesses.mu.Unlock()
+	localProcesses.usageDB = db
+}
+
+// Stop every process server and wait for them to exit. Process servers are children
+// of the bot and can't be picked up again after a restart, so they go down with it.
+func StopLocalProcesses() {
+	localProcesses.mu.Lock()
+	var names []string
+	var exited []chan struct{}
+	for name, process := range localProcesses.running {
+		names = append(names, name)
+		exited = append(exited, process.exited)
+	}
+	db := localProcesses.usageDB
+	localProcesses.mu.Unlock()
+
+	for _, name := range names {
+		log.Printf("Stopping process %s for shutdown", name)
+		server := &gameServer{Namespace: processNamespace, Name: name, backend: localProcesses}
+		if err := localProcesses.Stop(server); err != nil {
+			log.Printf("Failed to stop process %s: %v", name, err)
+			continue
+		}
+		if db != nil {
+			recordServerUsage(db, server, "stop", "", "shutdown")
+		}
+	}
+	// Stop kills anything still running after the timeout, so this always finishes
+	for _, done := range exited {
+		<-done
+	}
+}
+
+// The bot's environment without its secrets
+func processEnv() []string {
+	var env []string
+	for _, entry := range os.Environ() {
+		key, _, _ := strings.Cut(entry, "=")
+		if !slices.Contains(processHiddenEnv, key) {
+			env = append(env, entry)
+		}
+	}
+	return env
+}
+
+func (b *processBackend) Status(server *gameServer) (*discordgo.MessageEmbed, error) {
+	state := server.State()
+	color := 0xe74c3c
+	if state == "running" {
+		color = 0x2ecc71
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       server.DisplayName,
+		Description: fmt.Sprintf("%s `%s` - %s", server.Kind, server.ID(), state),
+		Color:       color,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	definition := b.definitions[server.Name]
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "Command",
+		Value: truncateField(fmt.Sprintf("`%s`", strings.Join(definition.Command, " "))),
+	})
+	if definition.WorkingDir != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Working Directory",
+			Value: fmt.Sprintf("`%s`", definition.WorkingDir),
+		})
+	}
+
+	if process, ok := b.running[server.Name]; ok {
+		value := fmt.Sprintf("PID %d, up %s", process.cmd.Process.Pid, duration.HumanDuration(time.Since(process.startedAt)))
+		if process.stopping {
+			value += ", stopping"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Process",
+			Value: value,
+		})
+	}
+
+	if exit, ok := b.exits[server.Name]; ok {
+		value := fmt.Sprintf("Exited cleanly %s ago", duration.HumanDuration(time.Since(exit.At)))
+		if exit.Err != nil {
+			value = fmt.Sprintf("%v, %s ago", exit.Err, duration.HumanDuration(time.Since(exit.At)))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Last Exit",
+			Value: value,
+		})
+	}
+
+	return embed, nil
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	rolloutPollInterval   = 3 * time.Second
+	rolloutDefaultTimeout = 5 * time.Minute
+	// Interaction tokens expire after 15 minutes, so edits past this point would fail
+	rolloutMaxTimeout = 14 * time.Minute
+)
+
+// Container waiting reasons that won't resolve on their own
+var rolloutFailureReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// rolloutProgress is how far the newest pod of a server has come
+type rolloutProgress struct {
+	Pod       string
+	Node      string
+	Scheduled bool
+	Pulled    bool
+	Started   bool
+	Ready     bool
+	Failed    bool
+	// Why the pod is stuck or failed, if known
+	Reason string
+}
+
+// Work out the progress of the newest pod created at or after since
+func evaluateRollout(pods []corev1.Pod, since time.Time) rolloutProgress {
+	var pod *corev1.Pod
+	for idx := range pods {
+		candidate := &pods[idx]
+		if candidate.DeletionTimestamp != nil || candidate.CreationTimestamp.Time.Before(since) {
+			continue
+		}
+		if pod == nil || pod.CreationTimestamp.Before(&candidate.CreationTimestamp) {
+			pod = candidate
+		}
+	}
+
+	var progress rolloutProgress
+	if pod == nil {
+		return progress
+	}
+	progress.Pod = pod.Name
+	progress.Node = pod.Spec.NodeName
+
+	for _, condition := range pod.Status.Conditions {
+		switch condition.Type {
+		case corev1.PodScheduled:
+			progress.Scheduled = condition.Status == corev1.ConditionTrue
+			if !progress.Scheduled && condition.Message != "" {
+				progress.Reason = condition.Message
+			}
+		case corev1.PodReady:
+			progress.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	if len(pod.Status.ContainerStatuses) > 0 {
+		pulled, started := true, true
+		for _, container := range pod.Status.ContainerStatuses {
+			if container.ImageID == "" {
+				pulled = false
+			}
+			if container.State.Running == nil {
+				started = false
+			}
+			if waiting := container.State.Waiting; waiting != nil && waiting.Reason != "" {
+				progress.Reason = waiting.Reason
+				if waiting.Message != "" {
+					progress.Reason += ": " + waiting.Message
+				}
+				if rolloutFailureReasons[waiting.Reason] {
+					progress.Failed = true
+				}
+			}
+		}
+		progress.Pulled = pulled
+		progress.Started = started
+	}
+
+	if pod.Status.Phase == corev1.PodFailed {
+		progress.Failed = true
+		if pod.Status.Reason != "" {
+			progress.Reason = pod.Status.Reason
+		}
+	}
+
+	return progress
+}
+
+// Render progress as a checklist for an interaction message
+func describeRollout(progress rolloutProgress) string {
+	steps := []struct {
+		done  bool
+		label string
+	}{
+		{progress.Scheduled, "Pod scheduled"},
+		{progress.Pulled, "Image pulled"},
+		{progress.Started, "Container started"},
+		{progress.Ready, "Readiness passed"},
+	}
+	if progress.Scheduled && progress.Node != "" {
+		steps[0].label = fmt.Sprintf("Pod scheduled on `%s`", progress.Node)
+	}
+
+	var lines []string
+	current := true
+	for _, step := range steps {
+		switch {
+		case step.done:
+			lines = append(lines, "✅ "+step.label)
+		case current:
+			lines = append(lines, "⏳ "+step.label)
+			current = false
+		default:
+			lines = append(lines, "⬜ "+step.label)
+		}
+	}
+	if progress.Reason != "" && !progress.Ready {
+		lines = append(lines, fmt.Sprintf("ℹ️ %s", progress.Reason))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Poll a server's pods until the newest one is ready, fails, or the timeout passes.
+// onProgress is called whenever the progress changes.
+func waitForRollout(server *gameServer, since time.Time, timeout time.Duration, onProgress func(rolloutProgress)) (rolloutProgress, error) {
+	deadline := time.Now().Add(timeout)
+	var last rolloutProgress
+
+	for {
+		pods, err := listServerPods(server)
+		if err != nil {
+			return last, err
+		}
+
+		progress := evaluateRollout(pods, since)
+		if progress != last {
+			last = progress
+			onProgress(progress)
+		}
+		if progress.Ready || progress.Failed {
+			return progress, nil
+		}
+		if time.Now().After(deadline) {
+			return progress, fmt.Errorf("timed out after %s", timeout)
+		}
+		time.Sleep(rolloutPollInterval)
+	}
+}
+
+// Per-server rollout timeout from the start-timeout annotation
+func rolloutTimeout(server *gameServer) time.Duration {
+	value, ok := server.Annotation("start-timeout")
+	if !ok {
+		return rolloutDefaultTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return rolloutDefaultTimeout
+	}
+	if timeout > rolloutMaxTimeout {
+		return rolloutMaxTimeout
+	}
+	return timeout
+}
+
+// Edit a deferred start or restart response as the server's new pod progresses, then report the outcome
+func followServerRollout(s *discordgo.Session, i *discordgo.InteractionCreate, server *gameServer, since time.Time, action string, config *util.JuiceBotConfig) {
+	header := fmt.Sprintf("🟡 Starting server **%s** (%s)", server.Name, server.ID())
+	if action == "restart" {
+		header = fmt.Sprintf("🔄 Restarting server **%s** (%s)", server.Name, server.ID())
+	}
+	content := header
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+
+	// Pods created slightly before the API call returned still belong to this rollout
+	progress, err := waitForRollout(server, since.Add(-5*time.Second), rolloutTimeout(server), func(progress rolloutProgress) {
+		content := header + "\n" + describeRollout(progress)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+	})
+
+	switch {
+	case err != nil:
+		log.Printf("Server %s did not become ready after %s for user %s in guild %s: %v", server.ID(), action, i.Member.User.ID, i.GuildID, err)
+		content = fmt.Sprintf("⌛ Server **%s** (%s) did not become ready: %v", server.Name, server.ID(), err)
+	case progress.Failed:
+		log.Printf("Server %s failed to %s for user %s in guild %s: %s", server.ID(), action, i.Member.User.ID, i.GuildID, progress.Reason)
+		content = fmt.Sprintf("❌ Server **%s** (%s) failed to %s", server.Name, server.ID(), action)
+	default:
+		content = fmt.Sprintf("🟢 Server **%s** (%s) is ready after %s", server.Name, server.ID(), time.Since(since).Round(time.Second))
+		if addresses := connectAddresses(config, server); len(addresses) > 0 {
+			content += "\nConnect: " + strings.Join(addresses, ", ")
+		}
+	}
+	content += "\n" + describeRollout(progress)
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+}
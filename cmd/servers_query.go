@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// playerInfo is what a game query protocol reports about who is online
+type playerInfo struct {
+	Online int
+	Max    int
+	Names  []string
+}
+
+// queryProtocol is a way of asking a game server who is online
+type queryProtocol struct {
+	DefaultPort int
+	Query       func(address string, timeout time.Duration) (*playerInfo, error)
+}
+
+// Protocols selectable with the query annotation
+var queryProtocols = map[string]queryProtocol{
+	"minecraft": {DefaultPort: 25565, Query: queryMinecraft},
+	"a2s":       {DefaultPort: 27015, Query: queryA2S},
+}
+
+// Query a Minecraft server with the Server List Ping protocol
+func queryMinecraft(address string, timeout time.Duration) (*playerInfo, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %v", portString, err)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// Handshake with next state 1 (status), followed by an empty status request
+	var handshake bytes.Buffer
+	writeVarInt(&handshake, 0x00)
+	writeVarInt(&handshake, -1)
+	writeVarInt(&handshake, int32(len(host)))
+	handshake.WriteString(host)
+	binary.Write(&handshake, binary.BigEndian, uint16(port))
+	writeVarInt(&handshake, 1)
+
+	var packets bytes.Buffer
+	writeVarInt(&packets, int32(handshake.Len()))
+	packets.Write(handshake.Bytes())
+	writeVarInt(&packets, 1)
+	writeVarInt(&packets, 0x00)
+	if _, err := conn.Write(packets.Bytes()); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	if _, err := readVarInt(reader); err != nil {
+		return nil, fmt.Errorf("failed to read packet length: %v", err)
+	}
+	packetID, err := readVarInt(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read packet id: %v", err)
+	}
+	if packetID != 0x00 {
+		return nil, fmt.Errorf("unexpected packet id %d", packetID)
+	}
+	length, err := readVarInt(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response length: %v", err)
+	}
+	if length < 0 || length > 1<<20 {
+		return nil, fmt.Errorf("invalid response length %d", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var status struct {
+		Players struct {
+			Online int `json:"online"`
+			Max    int `json:"max"`
+			Sample []struct {
+				Name string `json:"name"`
+			} `json:"sample"`
+		} `json:"players"`
+	}
+	if err := json.Unmarshal(payload, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse status response: %v", err)
+	}
+
+	info := &playerInfo{
+		Online: status.Players.Online,
+		Max:    status.Players.Max,
+	}
+	for _, player := range status.Players.Sample {
+		info.Names = append(info.Names, player.Name)
+	}
+	return info, nil
+}
+
+func writeVarInt(buf *bytes.Buffer, value int32) {
+	v := uint32(value)
+	for {
+		if v&^0x7f == 0 {
+			buf.WriteByte(byte(v))
+			return
+		}
+		buf.WriteByte(byte(v&0x7f | 0x80))
+		v >>= 7
+	}
+}
+
+func readVarInt(reader io.ByteReader) (int32, error) {
+	var value uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return int32(value), nil
+		}
+	}
+	return 0, errors.New("varint too long")
+}
+
+// A2S packets start with this header unless the response was split
+const a2sSimpleHeader = -1
+
+// Query a Source engine server with A2S_INFO for the player count and A2S_PLAYER for names
+func queryA2S(address string, timeout time.Duration) (*playerInfo, error) {
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	infoRequest := append([]byte{0xff, 0xff, 0xff, 0xff, 'T'}, []byte("Source Engine Query\x00")...)
+	info, err := a2sRequest(conn, infoRequest, 'I', true)
+	if err != nil {
+		return nil, fmt.Errorf("A2S_INFO failed: %v", err)
+	}
+
+	result, err := parseA2SInfo(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse A2S_INFO: %v", err)
+	}
+	if result.Online == 0 {
+		return result, nil
+	}
+
+	// A2S_PLAYER always needs a challenge, which a request with -1 asks for
+	players, err := a2sRequest(conn, []byte{0xff, 0xff, 0xff, 0xff, 'U', 0xff, 0xff, 0xff, 0xff}, 'D', false)
+	if err != nil {
+		// The count is still useful without names
+		return result, nil
+	}
+	result.Names = parseA2SPlayers(players)
+	return result, nil
+}
+
+// Read the player counts from an A2S_INFO payload, after the type byte
+func parseA2SInfo(payload []byte) (*playerInfo, error) {
+	reader := bytes.NewReader(payload)
+	// Protocol version, then name, map, folder and game strings and the app ID
+	if _, err := reader.ReadByte(); err != nil {
+		return nil, err
+	}
+	for range 4 {
+		if _, err := readCString(reader); err != nil {
+			return nil, err
+		}
+	}
+	var appID uint16
+	if err := binary.Read(reader, binary.LittleEndian, &appID); err != nil {
+		return nil, err
+	}
+	online, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	maxPlayers, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	return &playerInfo{
+		Online: int(online),
+		Max:    int(maxPlayers),
+	}, nil
+}
+
+// Read player names from an A2S_PLAYER payload, after the type byte. A truncated
+// payload gives the names read before it ended.
+func parseA2SPlayers(payload []byte) []string {
+	var names []string
+	reader := bytes.NewReader(payload)
+	count, err := reader.ReadByte()
+	if err != nil {
+		return nil
+	}
+	for range count {
+		// Index, name, score (int32) and duration (float32)
+		if _, err := reader.ReadByte(); err != nil {
+			break
+		}
+		name, err := readCString(reader)
+		if err != nil {
+			break
+		}
+		if _, err := reader.Seek(8, io.SeekCurrent); err != nil {
+			break
+		}
+		// Players still connecting have no name yet
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Send an A2S request, answering a challenge if the server sends one, and return the payload after the expected type byte.
+// infoChallenge appends the challenge to the original request as A2S_INFO expects, rather than replacing the last four bytes.
+func a2sRequest(conn net.Conn, request []byte, expected byte, infoChallenge bool) ([]byte, error) {
+	buf := make([]byte, 1400)
+	for attempt := 0; attempt < 2; attempt++ {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n < 5 {
+			return nil, fmt.Errorf("short response of %d bytes", n)
+		}
+		if int32(binary.LittleEndian.Uint32(buf[:4])) != a2sSimpleHeader {
+			return nil, errors.New("split responses are not supported")
+		}
+
+		switch buf[4] {
+		case expected:
+			return slices.Clone(buf[5:n]), nil
+		case 'A':
+			if n < 9 {
+				return nil, errors.New("short challenge response")
+			}
+			challenge := buf[5:9]
+			if infoChallenge {
+				request = append(slices.Clone(request), challenge...)
+			} else {
+				request = append(slices.Clone(request[:len(request)-4]), challenge...)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected response type %q", buf[4])
+		}
+	}
+	return nil, errors.New("server kept sending challenges")
+}
+
+func readCString(reader *bytes.Reader) (string, error) {
+	var value []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == 0 {
+			return string(value), nil
+		}
+		value = append(value, b)
+	}
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestVarIntRoundTrip(t *testing.T) {
+	tests := []struct {
+		value int32
+		want  []byte
+	}{
+		{value: 0, want: []byte{0x00}},
+		{value: 1, want: []byte{0x01}},
+		{value: 127, want: []byte{0x7f}},
+		{value: 128, want: []byte{0x80, 0x01}},
+		{value: 255, want: []byte{0xff, 0x01}},
+		{value: 25565, want: []byte{0xdd, 0xc7, 0x01}},
+		{value: 2097151, want: []byte{0xff, 0xff, 0x7f}},
+		{value: math.MaxInt32, want: []byte{0xff, 0xff, 0xff, 0xff, 0x07}},
+		{value: -1, want: []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+		{value: math.MinInt32, want: []byte{0x80, 0x80, 0x80, 0x80, 0x08}},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		writeVarInt(&buf, tt.value)
+		if !bytes.Equal(buf.Bytes(), tt.want) {
+			t.Errorf("writeVarInt(%d) = % x, want % x", tt.value, buf.Bytes(), tt.want)
+		}
+		got, err := readVarInt(bytes.NewReader(tt.want))
+		if err != nil || got != tt.value {
+			t.Errorf("readVarInt(% x) = %d, %v, want %d", tt.want, got, err, tt.value)
+		}
+	}
+}
+
+func TestReadVarIntErrors(t *testing.T) {
+	if _, err := readVarInt(bytes.NewReader([]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x01})); err == nil {
+		t.Error("readVarInt accepted a varint longer than five bytes")
+	}
+	if _, err := readVarInt(bytes.NewReader([]byte{0x80})); err != io.EOF {
+		t.Errorf("readVarInt of a truncated varint = %v, want EOF", err)
+	}
+}
+
+// An A2S_INFO payload as a Team Fortress 2 server sends it, after the header and type byte
+var a2sInfoPayload = []byte("\x11" +
+	"Juice TF2\x00" +
+	"cp_badlands\x00" +
+	"tf\x00" +
+	"Team Fortress\x00" +
+	"\xb8\x01" + // app ID 440
+	"\x03" + // players
+	"\x18" + // max players
+	"\x00" + // bots
+	"dl\x00\x01" + // dedicated, Linux, public, VAC
+	"9543365\x00" +
+	"\xb1\x87\x69") // extra data flag and game port
+
+// An A2S_PLAYER payload with one player still connecting, after the header and type byte
+var a2sPlayerPayload = a2sPlayers([]string{"Scout", "", "Heavy"})
+
+func a2sPlayers(names []string) []byte {
+	var payload bytes.Buffer
+	payload.WriteByte(byte(len(names)))
+	for idx, name := range names {
+		payload.WriteByte(byte(idx))
+		payload.WriteString(name + "\x00")
+		binary.Write(&payload, binary.LittleEndian, int32(idx*7))
+		binary.Write(&payload, binary.LittleEndian, float32(idx)*61.5)
+	}
+	return payload.Bytes()
+}
+
+func TestParseA2SInfo(t *testing.T) {
+	info, err := parseA2SInfo(a2sInfoPayload)
+	if err != nil {
+		t.Fatalf("parseA2SInfo: %v", err)
+	}
+	if info.Online != 3 || info.Max != 24 {
+		t.Errorf("parseA2SInfo = %d/%d players, want 3/24", info.Online, info.Max)
+	}
+
+	// Every prefix that stops before the player counts is an error
+	for length := range 44 {
+		if _, err := parseA2SInfo(a2sInfoPayload[:length]); err == nil {
+			t.Errorf("parseA2SInfo accepted a payload truncated to %d bytes", length)
+		}
+	}
+}
+
+func TestParseA2SPlayers(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    []string
+	}{
+		{name: "players", payload: a2sPlayerPayload, want: []string{"Scout", "Heavy"}},
+		{name: "empty", payload: []byte{0x00}, want: nil},
+		{name: "no payload", payload: nil, want: nil},
+		{name: "truncated", payload: a2sPlayerPayload[:len(a2sPlayerPayload)-10], want: []string{"Scout"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseA2SPlayers(tt.payload); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: parseA2SPlayers = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Serve A2S over UDP, asking for a challenge before answering each request
+func serveA2S(t *testing.T, conn net.PacketConn) {
+	challenge := []byte{0x4a, 0x55, 0x49, 0x43}
+	header := []byte{0xff, 0xff, 0xff, 0xff}
+	buf := make([]byte, 1400)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		request := buf[:n]
+
+		var response []byte
+		switch {
+		case !bytes.HasSuffix(request, challenge):
+			response = append(slices.Clone(header), 'A')
+			response = append(response, challenge...)
+		case request[4] == 'T':
+			if !bytes.HasPrefix(request, []byte("\xff\xff\xff\xffTSource Engine Query\x00")) {
+				t.Errorf("A2S_INFO request % x lost its payload when the challenge was added", request)
+			}
+			response = append(slices.Clone(header), 'I')
+			response = append(response, a2sInfoPayload...)
+		case request[4] == 'U' && n == 9:
+			response = append(slices.Clone(header), 'D')
+			response = append(response, a2sPlayerPayload...)
+		default:
+			t.Errorf("unexpected A2S request % x", request)
+			return
+		}
+		conn.WriteTo(response, addr)
+	}
+}
+
+func TestQueryA2S(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can't listen on UDP: %v", err)
+	}
+	defer conn.Close()
+	go serveA2S(t, conn)
+
+	info, err := queryA2S(conn.LocalAddr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("queryA2S: %v", err)
+	}
+	if info.Online != 3 || info.Max != 24 || !slices.Equal(info.Names, []string{"Scout", "Heavy"}) {
+		t.Errorf("queryA2S = %+v, want 3/24 players named Scout and Heavy", info)
+	}
+}
+
+func TestQueryMinecraft(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can't listen on TCP: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		// Handshake, then the status request
+		for range 2 {
+			length, err := readVarInt(reader)
+			if err != nil {
+				t.Errorf("reading request length: %v", err)
+				return
+			}
+			if _, err := io.CopyN(io.Discard, reader, int64(length)); err != nil {
+				t.Errorf("reading request: %v", err)
+				return
+			}
+		}
+
+		status, _ := json.Marshal(map[string]any{
+			"version": map[string]any{"name": "1.21.1", "protocol": 767},
+			"players": map[string]any{
+				"online": 2,
+				"max":    20,
+				"sample": []map[string]string{{"name": "Steve", "id": "1"}, {"name": "Alex", "id": "2"}},
+			},
+			"description": "A Minecraft Server",
+		})
+		var body bytes.Buffer
+		writeVarInt(&body, 0x00)
+		writeVarInt(&body, int32(len(status)))
+		body.Write(status)
+		var packet bytes.Buffer
+		writeVarInt(&packet, int32(body.Len()))
+		packet.Write(body.Bytes())
+		conn.Write(packet.Bytes())
+	}()
+
+	info, err := queryMinecraft(listener.Addr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("queryMinecraft: %v", err)
+	}
+	if info.Online != 2 || info.Max != 20 || !slices.Equal(info.Names, []string{"Steve", "Alex"}) {
+		t.Errorf("queryMinecraft = %+v, want 2/20 players named Steve and Alex", info)
+	}
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Discord allows 5 rows of 5 buttons on a message
+const quotaMaxStopButtons = 25
+
+var errQuotaExceeded = errors.New("guild quota reached")
+
+// guildQuota is a guild's limits from config, zero values are unlimited
+type guildQuota struct {
+	MaxRunning int
+	// CPU in millicores and memory in bytes
+	CPU    int64
+	Memory int64
+}
+
+// Look up and parse the quota for a guild. Budgets that fail to parse are ignored.
+func guildQuotaFor(config *util.JuiceBotConfig, guildID string) (*guildQuota, bool) {
+	for _, configured := range config.Servers.GuildQuotas {
+		if configured.GuildID != guildID {
+			continue
+		}
+		quota := &guildQuota{MaxRunning: configured.MaxRunning}
+		if configured.CPU != "" {
+			if cpu, err := resource.ParseQuantity(configured.CPU); err != nil {
+				log.Printf("Ignoring invalid CPU quota %q for guild %s: %v", configured.CPU, guildID, err)
+			} else {
+				quota.CPU = cpu.MilliValue()
+			}
+		}
+		if configured.Memory != "" {
+			if memory, err := resource.ParseQuantity(configured.Memory); err != nil {
+				log.Printf("Ignoring invalid memory quota %q for guild %s: %v", configured.Memory, guildID, err)
+			} else {
+				quota.Memory = memory.Value()
+			}
+		}
+		return quota, true
+	}
+	return nil, false
+}
+
+// Sum container requests in a pod spec
+func podSpecRequests(spec corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			total := requests[name]
+			total.Add(quantity)
+			requests[name] = total
+		}
+	}
+	return requests
+}
+
+// Requested CPU in millicores and memory in bytes across a number of a server's pods
+func serverRequests(server *gameServer, replicas int32) (int64, int64) {
+	cpu := server.PodRequests[corev1.ResourceCPU]
+	memory := server.PodRequests[corev1.ResourceMemory]
+	return cpu.MilliValue() * int64(replicas), memory.Value() * int64(replicas)
+}
+
+// Check whether starting a server keeps the guild within its quota. Returns why it wouldn't,
+// or an empty string if it fits, along with the guild's other running servers.
+func checkGuildQuota(config *util.JuiceBotConfig, guildID string, server *gameServer) (string, []*gameServer, error) {
+	quota, ok := guildQuotaFor(config, guildID)
+	if !ok {
+		return "", nil, nil
+	}
+
+	// A backend that can't be listed only blocks the check if it is the server's own,
+	// since its running servers are then unknown. Others are left out of the count.
+	var servers []*gameServer
+	for _, backend := range gameServerBackends(config) {
+		backendServers, err := backend.List(config, guildID)
+		if err != nil {
+			if backend == server.backend {
+				return "", nil, err
+			}
+			log.Printf("Checking quota for %s in guild %s without an unreachable backend: %v", server.ID(), guildID, err)
+			continue
+		}
+		servers = append(servers, backendServers...)
+	}
+
+	var running []*gameServer
+	var cpu, memory int64
+	for _, other := range servers {
+		if other.Replicas == 0 || other.ID() == server.ID() {
+			continue
+		}
+		running = append(running, other)
+		otherCPU, otherMemory := serverRequests(other, other.Replicas)
+		cpu += otherCPU
+		memory += otherMemory
+	}
+
+	// Starting scales a server to a single pod
+	startCPU, startMemory := serverRequests(server, 1)
+	switch {
+	case quota.MaxRunning > 0 && len(running) >= quota.MaxRunning:
+		return fmt.Sprintf("this guild can only run %d servers at once", quota.MaxRunning), running, nil
+	case quota.CPU > 0 && cpu+startCPU > quota.CPU:
+		return fmt.Sprintf("it requests %s CPU and %s of this guild's %s are in use",
+			formatCPU(float64(startCPU)), formatCPU(float64(cpu)), formatCPU(float64(quota.CPU))), running, nil
+	case quota.Memory > 0 && memory+startMemory > quota.Memory:
+		return fmt.Sprintf("it requests %s memory and %s of this guild's %s are in use",
+			formatMemory(float64(startMemory)), formatMemory(float64(memory)), formatMemory(float64(quota.Memory))), running, nil
+	}
+	return "", running, nil
+}
+
+// Refuse a start that would go over the guild's quota, offering to stop a running server
+// instead. The refusal is sent with respond. Returns whether the start may go ahead.
+func enforceGuildQuota(s *discordgo.Session, i *discordgo.InteractionCreate, server *gameServer, config *util.JuiceBotConfig, db *sql.DB, respond func(content string, components []discordgo.MessageComponent)) bool {
+	reason, running, err := checkGuildQuota(config, i.GuildID, server)
+	if err != nil {
+		log.Printf("Failed to check quota for %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		respond("❌ Unable to check this guild's server quota", []discordgo.MessageComponent{})
+		return false
+	}
+	if reason == "" {
+		return true
+	}
+
+	log.Printf("User %s in guild %s was refused starting %s: %s", i.Member.User.ID, i.GuildID, server.ID(), reason)
+	auditServerResult(s, server, i.GuildID, i.Member.User.ID, "start", auditDenied, fmt.Errorf("%w: %s", errQuotaExceeded, reason), config, db)
+	respond(describeQuotaRefusal(server, reason, running), quotaStopComponents(running))
+	return false
+}
+
+// Respond by editing a deferred response, for enforceGuildQuota
+func editDeferredResponse(s *discordgo.Session, i *discordgo.InteractionCreate) func(string, []discordgo.MessageComponent) {
+	return func(content string, components []discordgo.MessageComponent) {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Components: &components,
+		})
+	}
+}
+
+// Explain why a start was refused, listing the running servers that could be stopped instead
+func describeQuotaRefusal(server *gameServer, reason string, running []*gameServer) string {
+	content := fmt.Sprintf("❌ Can't start **%s**, %s", server.DisplayName, reason)
+	if len(running) > 0 {
+		content += "\n**Running:**\n"
+		for _, other := range running {
+			content += fmt.Sprintf("🟢 **%s** (%s)", other.DisplayName, other.ID())
+			if cpu, memory := serverRequests(other, other.Replicas); cpu > 0 || memory > 0 {
+				content += fmt.Sprintf(" - requests %s CPU, %s memory", formatCPU(float64(cpu)), formatMemory(float64(memory)))
+			}
+			content += "\n"
+		}
+		content += "Stop one to make room, then start again"
+	}
+	return content
+}
+
+func quotaStopComponents(running []*gameServer) []discordgo.MessageComponent {
+	components := []discordgo.MessageComponent{}
+	var row []discordgo.MessageComponent
+	for idx, server := range running {
+		if idx == quotaMaxStopButtons {
+			break
+		}
+		row = append(row, discordgo.Button{
+			Label:    truncateLine("Stop "+server.DisplayName, 80),
+			Style:    discordgo.DangerButton,
+			CustomID: serverCustomID("servers:quotastop:", server.ID()),
+		})
+		if len(row) == 5 {
+			components = append(components, discordgo.ActionsRow{Components: row})
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		components = append(components, discordgo.ActionsRow{Components: row})
+	}
+	return components
+}
+
+// Stop a server from a quota message, with the same role checks as /servers stop
+func handleQuotaStopButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverID string, config *util.JuiceBotConfig, db *sql.DB) {
+	respondEphemeral := func(content string) {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+				AllowedMentions: &discordgo.MessageAllowedMentions{
+					Parse: []discordgo.AllowedMentionType{},
+				},
+			},
+		})
+	}
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		respondEphemeral(describeLookupError(i, serverID, err, "stop server"))
+		return
+	}
+
+	if !checkServerRoles(s, i, server, "stop", config, db, respondEphemeral) {
+		return
+	}
+
+	if server.Replicas == 0 {
+		respondEphemeral(fmt.Sprintf("❌ Server **%s** is already stopped!", server.DisplayName))
+		return
+	}
+
+	err = server.backend.Stop(server)
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, "stop", err, config, db)
+	if err != nil {
+		log.Printf("Failed to stop %s %s for user %s in guild %s: %v", strings.ToLower(server.Kind), server.ID(), i.Member.User.ID, i.GuildID, err)
+		respondEphemeral("❌ Unable to stop server")
+		return
+	}
+
+	log.Printf("User %s in guild %s stopped %s to make room", i.Member.User.ID, i.GuildID, server.ID())
+	recordServerUsage(db, server, "stop", i.Member.User.ID, "command")
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("🔴 <@%s> stopped **%s** (%s) to make room, try starting again", i.Member.User.ID, server.DisplayName, server.ID()),
+			Components: []discordgo.MessageComponent{},
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Parse: []discordgo.AllowedMentionType{},
+			},
+		},
+	})
+}
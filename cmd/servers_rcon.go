@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	rconTimeout     = 5 * time.Second
+	rconDefaultPort = "25575"
+	// Responses split over several packets are read until the server goes quiet for this long
+	rconFollowupWait = 250 * time.Millisecond
+	// Bodies are at most 4096 bytes, anything much larger is a broken or hostile server
+	rconMaxPacketSize = 1 << 16
+	// Leave room for the code block around the output
+	rconMaxOutput = 1800
+	// The command is echoed in the reply too, which must stay under 2000 characters
+	rconMaxEcho = 100
+	// Source consoles run commands split by these one after another
+	rconCommandSeparators = ";\n\r\x00"
+)
+
+// Source RCON packet types, which Minecraft also uses
+const (
+	rconTypeCommand  = 2
+	rconTypeAuthResp = 2
+	rconTypeAuth     = 3
+)
+
+var (
+	errRconAuth = errors.New("rcon authentication failed")
+	// Minecraft formatting codes like §a, which Discord can't render
+	minecraftFormatting = regexp.MustCompile("§.")
+)
+
+// Run a single command over Source RCON and return its output
+func rconExecute(address string, password string, command string, timeout time.Duration) (string, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := writeRconPacket(conn, 1, rconTypeAuth, password); err != nil {
+		return "", err
+	}
+	// Source servers send an empty response before the auth result, Minecraft only sends the result
+	for {
+		id, packetType, _, err := readRconPacket(conn)
+		if err != nil {
+			return "", err
+		}
+		if packetType != rconTypeAuthResp {
+			continue
+		}
+		if id == -1 {
+			return "", errRconAuth
+		}
+		break
+	}
+
+	if err := writeRconPacket(conn, 2, rconTypeCommand, command); err != nil {
+		return "", err
+	}
+	_, _, body, err := readRconPacket(conn)
+	if err != nil {
+		return "", err
+	}
+
+	// Long output arrives as several packets with no end marker that every server understands
+	var output strings.Builder
+	output.WriteString(body)
+	for {
+		conn.SetReadDeadline(time.Now().Add(rconFollowupWait))
+		id, _, body, err := readRconPacket(conn)
+		if err != nil {
+			break
+		}
+		if id == 2 {
+			output.WriteString(body)
+		}
+	}
+	return output.String(), nil
+}
+
+func writeRconPacket(w io.Writer, id int32, packetType int32, body string) error {
+	var packet bytes.Buffer
+	binary.Write(&packet, binary.LittleEndian, int32(len(body)+10))
+	binary.Write(&packet, binary.LittleEndian, id)
+	binary.Write(&packet, binary.LittleEndian, packetType)
+	packet.WriteString(body)
+	// Null terminated body followed by an empty string
+	packet.Write([]byte{0, 0})
+	_, err := w.Write(packet.Bytes())
+	return err
+}
+
+func readRconPacket(r io.Reader) (int32, int32, string, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return 0, 0, "", err
+	}
+	if size < 10 || size > rconMaxPacketSize {
+		return 0, 0, "", fmt.Errorf("invalid rcon packet size %d", size)
+	}
+
+	packet := make([]byte, size)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return 0, 0, "", err
+	}
+	id := int32(binary.LittleEndian.Uint32(packet[0:4]))
+	packetType := int32(binary.LittleEndian.Uint32(packet[4:8]))
+	body := string(bytes.TrimRight(packet[8:], "\x00"))
+	return id, packetType, body, nil
+}
+
+// Check a command against the rcon-commands annotation, a comma separated list of allowed
+// commands like "say,kick,time set". A command is allowed if it starts with one of them.
+// "*" allows everything, and servers without the annotation allow nothing. Commands with
+// separators are always refused, since Source consoles run each part as its own command.
+func rconCommandAllowed(server *gameServer, command string) bool {
+	value, ok := server.Annotation("rcon-commands")
+	if !ok {
+		return false
+	}
+	if strings.ContainsAny(command, rconCommandSeparators) {
+		return false
+	}
+	command = strings.ToLower(strings.Join(strings.Fields(command), " "))
+	for _, allowed := range strings.Split(value, ",") {
+		allowed = strings.ToLower(strings.Join(strings.Fields(allowed), " "))
+		if allowed == "" {
+			continue
+		}
+		if allowed == "*" || command == allowed || strings.HasPrefix(command, allowed+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles allowed to use RCON on a server, from its rcon-roles annotation,
+// falling back to the roles allowed to manage it
+func allowedRconRoles(server *gameServer, guildID string, config *util.JuiceBotConfig) []string {
+	value, ok := server.Annotation("rcon-roles")
+	if !ok {
+		return allowedServerRoles(server, guildID, config)
+	}
+	var roles []string
+	for _, role := range strings.Split(value, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Read the RCON address and password from the Secret named by the rcon-secret annotation.
+// Without an address key, the server's ready pod is used on the port key or 25575.
+func rconCredentials(server *gameServer) (string, string, error) {
+	secretName, ok := server.Annotation("rcon-secret")
+	if !ok {
+		return "", "", fmt.Errorf("no %s annotation", server.AnnotationKey("rcon-secret"))
+	}
+	secret, err := server.cluster().client.CoreV1().Secrets(server.Namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get secret %s: %v", secretName, err)
+	}
+
+	password := string(secret.Data["password"])
+	if address := string(secret.Data["address"]); address != "" {
+		return address, password, nil
+	}
+
+	pods, err := listServerPods(server)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list pods: %v", err)
+	}
+	pod := readyPod(pods)
+	if pod == nil {
+		return "", "", errNoReadyPod
+	}
+	port := rconDefaultPort
+	if value := string(secret.Data["port"]); value != "" {
+		port = value
+	}
+	return net.JoinHostPort(pod.Status.PodIP, port), password, nil
+}
+
+func handleServerRcon(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
+	var serverID, command string
+	for _, opt := range options {
+		switch opt.Name {
+		case "server":
+			serverID = opt.StringValue()
+		case "command":
+			command = strings.TrimSpace(opt.StringValue())
+		}
+	}
+
+	// Output can include things like player IPs, so only the caller sees it
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	respond := func(content string) {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Parse: []discordgo.AllowedMentionType{},
+			},
+		})
+	}
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "run command")
+		respond(content)
+		return
+	}
+
+	if !server.onKubernetes() {
+		respond(fmt.Sprintf("❌ Server **%s** is a %s, which has no RCON", server.DisplayName, strings.ToLower(server.Kind)))
+		return
+	}
+
+	shown := truncateLine(command, rconMaxEcho)
+	action := "rcon " + shown
+	allowed := allowedRconRoles(server, i.GuildID, config)
+	if !isRoleAuthorized(i.Member, allowed) {
+		denyServerAction(s, i, server, action, allowed, config, db, respond)
+		return
+	}
+
+	if !rconCommandAllowed(server, command) {
+		log.Printf("User %s in guild %s was denied rcon command %q on %s", i.Member.User.ID, i.GuildID, command, server.ID())
+		auditServerResult(s, server, i.GuildID, i.Member.User.ID, action, auditDenied, nil, config, db)
+		content := fmt.Sprintf("❌ That command isn't allowed on **%s**", server.DisplayName)
+		if value, ok := server.Annotation("rcon-commands"); ok {
+			content += fmt.Sprintf(", allowed commands: `%s`", value)
+		}
+		respond(content)
+		return
+	}
+
+	if server.ReadyReplicas == 0 {
+		respond(fmt.Sprintf("❌ Server **%s** is not running", server.DisplayName))
+		return
+	}
+
+	address, password, err := rconCredentials(server)
+	if err != nil {
+		log.Printf("Failed to get rcon credentials for %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		respond(fmt.Sprintf("❌ RCON isn't set up for **%s**", server.DisplayName))
+		return
+	}
+
+	output, err := rconExecute(address, password, command, rconTimeout)
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, action, err, config, db)
+	if err != nil {
+		log.Printf("Failed to run rcon command on %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		respond(fmt.Sprintf("❌ Unable to run command on **%s**: %v", server.DisplayName, err))
+		return
+	}
+
+	log.Printf("User %s in guild %s ran rcon command %q on %s", i.Member.User.ID, i.GuildID, command, server.ID())
+	output = strings.TrimSpace(minecraftFormatting.ReplaceAllString(output, ""))
+	if output == "" {
+		respond(fmt.Sprintf("✅ Ran `%s` on **%s**, no output", shown, server.DisplayName))
+		return
+	}
+	output = truncateLine(strings.ReplaceAll(output, "```", "'''"), rconMaxOutput)
+	respond(fmt.Sprintf("✅ Ran `%s` on **%s**\n```\n%s\n```", shown, server.DisplayName, output))
+}
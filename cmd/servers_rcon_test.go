@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRconPacketRoundTrip(t *testing.T) {
+	tests := []struct {
+		id         int32
+		packetType int32
+		body       string
+	}{
+		{id: 1, packetType: rconTypeAuth, body: "hunter2"},
+		{id: 2, packetType: rconTypeCommand, body: "say hello world"},
+		{id: -1, packetType: rconTypeAuthResp, body: ""},
+		{id: 7, packetType: 0, body: strings.Repeat("x", 4096)},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeRconPacket(&buf, tt.id, tt.packetType, tt.body); err != nil {
+			t.Fatalf("writeRconPacket: %v", err)
+		}
+		if buf.Len() != len(tt.body)+14 {
+			t.Errorf("packet for %d byte body is %d bytes, want %d", len(tt.body), buf.Len(), len(tt.body)+14)
+		}
+		id, packetType, body, err := readRconPacket(&buf)
+		if err != nil {
+			t.Fatalf("readRconPacket: %v", err)
+		}
+		if id != tt.id || packetType != tt.packetType || body != tt.body {
+			t.Errorf("round trip of (%d, %d, %.20q) gave (%d, %d, %.20q)", tt.id, tt.packetType, tt.body, id, packetType, body)
+		}
+	}
+}
+
+func TestReadRconPacketErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+	}{
+		{name: "empty", packet: nil},
+		{name: "size too small", packet: []byte{9, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "size too large", packet: []byte{0, 0, 2, 0}},
+		{name: "negative size", packet: []byte{0xff, 0xff, 0xff, 0xff}},
+		{name: "truncated", packet: []byte{20, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		if _, _, _, err := readRconPacket(bytes.NewReader(tt.packet)); err == nil {
+			t.Errorf("%s: readRconPacket accepted % x", tt.name, tt.packet)
+		}
+	}
+}
+
+// Serve a single RCON connection, answering commands with the given response packets
+func serveRcon(t *testing.T, listener net.Listener, password string, sourceStyle bool, responses []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	id, packetType, body, err := readRconPacket(conn)
+	if err != nil || packetType != rconTypeAuth {
+		t.Errorf("expected an auth packet, got type %d: %v", packetType, err)
+		return
+	}
+	if sourceStyle {
+		writeRconPacket(conn, id, 0, "")
+	}
+	if body != password {
+		writeRconPacket(conn, -1, rconTypeAuthResp, "")
+		return
+	}
+	writeRconPacket(conn, id, rconTypeAuthResp, "")
+
+	id, packetType, _, err = readRconPacket(conn)
+	if err != nil || packetType != rconTypeCommand {
+		t.Errorf("expected a command packet, got type %d: %v", packetType, err)
+		return
+	}
+	for _, response := range responses {
+		writeRconPacket(conn, id, 0, response)
+	}
+	// Keep the connection open so the client stops on its quiet timeout
+	time.Sleep(2 * rconFollowupWait)
+}
+
+func TestRconExecute(t *testing.T) {
+	tests := []struct {
+		name        string
+		password    string
+		sourceStyle bool
+		responses   []string
+		want        string
+		wantErr     error
+	}{
+		{name: "single packet", password: "secret", responses: []string{"There are 0 of a max of 20 players online: "}, want: "There are 0 of a max of 20 players online: "},
+		{name: "multiple packets", password: "secret", responses: []string{strings.Repeat("a", 4096), strings.Repeat("b", 4096), "c"}, want: strings.Repeat("a", 4096) + strings.Repeat("b", 4096) + "c"},
+		{name: "source auth", password: "secret", sourceStyle: true, responses: []string{"ok"}, want: "ok"},
+		{name: "wrong password", password: "wrong", wantErr: errRconAuth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Skipf("can't listen on TCP: %v", err)
+			}
+			defer listener.Close()
+			go serveRcon(t, listener, "secret", tt.sourceStyle, tt.responses)
+
+			got, err := rconExecute(listener.Addr().String(), tt.password, "list", 2*time.Second)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("rconExecute error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("rconExecute = %.40q (%d bytes), want %.40q (%d bytes)", got, len(got), tt.want, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestRconCommandAllowed(t *testing.T) {
+	tests := []struct {
+		annotation string
+		command    string
+		want       bool
+	}{
+		{annotation: "", command: "say hi", want: false},
+		{annotation: "say,kick", command: "say hi", want: true},
+		{annotation: "say,kick", command: "SAY hi", want: true},
+		{annotation: "say,kick", command: "sayhi", want: false},
+		{annotation: "say,kick", command: "kick", want: true},
+		{annotation: "say,kick", command: "stop", want: false},
+		{annotation: "time set", command: "time  set day", want: true},
+		{annotation: "time set", command: "time query", want: false},
+		{annotation: " , *", command: "stop", want: true},
+		{annotation: "say", command: "say hi; rcon_password x; quit", want: false},
+		{annotation: "say", command: "say hi\nquit", want: false},
+		{annotation: "say", command: "say hi\rquit", want: false},
+		{annotation: "say", command: "say hi\x00quit", want: false},
+		{annotation: "*", command: "status;quit", want: false},
+	}
+
+	for _, tt := range tests {
+		server := &gameServer{Annotations: map[string]string{}, annotationPrefix: "juicebot-"}
+		if tt.annotation != "" {
+			server.Annotations[server.AnnotationKey("rcon-commands")] = tt.annotation
+		}
+		if got := rconCommandAllowed(server, tt.command); got != tt.want {
+			t.Errorf("rconCommandAllowed(%q, %q) = %v, want %v", tt.annotation, tt.command, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+)
+
+func handleRestartServer(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
+	if len(options) == 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Please specify a server ID to restart (format: namespace/name)",
+			},
+		})
+		return
+	}
+
+	serverID := options[0].StringValue()
+
+	// Defer so the response can follow the new pod until it is ready
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "restart server")
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	if !checkServerRoles(s, i, server, "restart", config, db, deferredReply(s, i)) {
+		return
+	}
+
+	if server.Replicas == 0 {
+		content := fmt.Sprintf("❌ Server **%s** is not running, use `/servers start` instead", server.Name)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	restartedAt := time.Now()
+	err = restartGameServer(server)
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, "restart", err, config, db)
+	if err != nil {
+		content := "❌ Unable to restart server"
+		if errors.Is(err, errRestartUnsupported) {
+			content = fmt.Sprintf("❌ Server **%s** is a %s, which can't be restarted", server.Name, server.Kind)
+		} else {
+			log.Printf("Failed to restart %s %s for user %s in guild %s: %v", strings.ToLower(server.Kind), server.ID(), i.Member.User.ID, i.GuildID, err)
+		}
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	log.Printf("User %s in guild %s restarted %s", i.Member.User.ID, i.GuildID, server.ID())
+	go followServerRollout(s, i, server, restartedAt, "restart", config)
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+)
+
+// Roles allowed to manage a server, from its roles annotation
+// or the guild default in config. An empty list means anyone in the guild may.
+func allowedServerRoles(server *gameServer, guildID string, config *util.JuiceBotConfig) []string {
+	if value, ok := server.Annotation("roles"); ok {
+		var roles []string
+		for _, role := range strings.Split(value, ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+		return roles
+	}
+
+	for _, guildRoles := range config.Servers.GuildRoles {
+		if guildRoles.GuildID == guildID {
+			return guildRoles.Roles
+		}
+	}
+	return nil
+}
+
+// Check a member against a list of allowed roles. Guild administrators are always allowed.
+func isRoleAuthorized(member *discordgo.Member, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	if member.Permissions&discordgo.PermissionAdministrator != 0 {
+		return true
+	}
+	for _, role := range member.Roles {
+		if slices.Contains(allowed, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check the invoking member may manage a server, sending a denial with respond if not.
+// Handlers call this once they have looked the server up.
+func checkServerRoles(s *discordgo.Session, i *discordgo.InteractionCreate, server *gameServer, action string, config *util.JuiceBotConfig, db *sql.DB, respond func(content string)) bool {
+	allowed := allowedServerRoles(server, i.GuildID, config)
+	if isRoleAuthorized(i.Member, allowed) {
+		return true
+	}
+	denyServerAction(s, i, server, action, allowed, config, db, respond)
+	return false
+}
+
+// Tell a member they lack the roles for an action with respond and audit the attempt
+func denyServerAction(s *discordgo.Session, i *discordgo.InteractionCreate, server *gameServer, action string, allowed []string, config *util.JuiceBotConfig, db *sql.DB, respond func(content string)) {
+	log.Printf("User %s in guild %s was denied %s on %s, requires one of roles %v", i.Member.User.ID, i.GuildID, action, server.ID(), allowed)
+	auditServerResult(s, server, i.GuildID, i.Member.User.ID, action, auditDenied, nil, config, db)
+
+	var mentions []string
+	for _, role := range allowed {
+		mentions = append(mentions, fmt.Sprintf("<@&%s>", role))
+	}
+	respond(fmt.Sprintf("❌ You need one of these roles to use `%s` on **%s**: %s", action, server.DisplayName, strings.Join(mentions, ", ")))
+}
+
+// Respond with a message only the member sees, for handlers that haven't responded yet
+func ephemeralReply(s *discordgo.Session, i *discordgo.InteractionCreate) func(string) {
+	return func(content string) {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+				AllowedMentions: &discordgo.MessageAllowedMentions{
+					Parse: []discordgo.AllowedMentionType{},
+				},
+			},
+		})
+	}
+}
+
+// Respond by replacing the content of a deferred response
+func deferredReply(s *discordgo.Session, i *discordgo.InteractionCreate) func(string) {
+	return func(content string) {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Parse: []discordgo.AllowedMentionType{},
+			},
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+)
+
+// Short name of the annotation holding a server's schedule
+const scheduleAnnotation = "schedule"
+
+// scheduleEntry is one "start <cron>" or "stop <cron>" clause of a schedule annotation
+type scheduleEntry struct {
+	Action   string
+	Spec     string
+	Schedule *cronSchedule
+}
+
+// Parse a schedule annotation such as "start 0 19 * * 5; stop 0 2 * * 6"
+func parseServerSchedule(value string) ([]scheduleEntry, error) {
+	var entries []scheduleEntry
+	for _, clause := range strings.Split(value, ";") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+
+		action, spec, _ := strings.Cut(clause, " ")
+		action = strings.ToLower(action)
+		if action != "start" && action != "stop" {
+			return nil, fmt.Errorf("clause %q must begin with start or stop", clause)
+		}
+		spec = strings.TrimSpace(spec)
+		schedule, err := parseCron(spec)
+		if err != nil {
+			return nil, fmt.Errorf("clause %q: %v", clause, err)
+		}
+		entries = append(entries, scheduleEntry{Action: action, Spec: spec, Schedule: schedule})
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("schedule is empty")
+	}
+	return entries, nil
+}
+
+func scheduleLocation(config *util.JuiceBotConfig) *time.Location {
+	if config.Servers.ScheduleTimezone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(config.Servers.ScheduleTimezone)
+	if err != nil {
+		log.Printf("Invalid schedule timezone %q, using local time: %v", config.Servers.ScheduleTimezone, err)
+		return time.Local
+	}
+	return location
+}
+
+type serverScheduler struct {
+	mu sync.Mutex
+	// Next fire time per server, entry index, and spec, so edits to the annotation reset it
+	next map[string]time.Time
+}
+
+var scheduler = &serverScheduler{next: map[string]time.Time{}}
+
+// Start the background loop that applies schedule annotations. Next fire times are
+// recomputed from the annotations on startup, so nothing needs to be persisted.
+func StartScheduler(s *discordgo.Session, config *util.JuiceBotConfig, db *sql.DB) {
+	if config.Servers.DisableKubernetes {
+		return
+	}
+
+	go func() {
+		// Line the ticks up with the start of each minute so cron times fire on time
+		time.Sleep(time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)))
+		scheduler.check(s, config, db)
+
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			scheduler.check(s, config, db)
+		}
+	}()
+}
+
+func (sc *serverScheduler) check(s *discordgo.Session, config *util.JuiceBotConfig, db *sql.DB) {
+	// Clusters that can't be reached are skipped until they come back
+	servers, err := listAllGameServers(config)
+	if err != nil {
+		log.Printf("Scheduler failed to list game servers: %v", err)
+	}
+
+	location := scheduleLocation(config)
+	now := time.Now().In(location)
+	seen := map[string]bool{}
+
+	for _, server := range servers {
+		value, ok := server.Annotation(scheduleAnnotation)
+		if !ok {
+			continue
+		}
+		entries, err := parseServerSchedule(value)
+		if err != nil {
+			log.Printf("Scheduler ignoring %s, invalid schedule %q: %v", server.ID(), value, err)
+			continue
+		}
+
+		// When several clauses are due at once the last one wins
+		var due *scheduleEntry
+		for idx := range entries {
+			entry := &entries[idx]
+			key := fmt.Sprintf("%s|%d|%s %s", server.ID(), idx, entry.Action, entry.Spec)
+			seen[key] = true
+
+			sc.mu.Lock()
+			next, ok := sc.next[key]
+			if !ok {
+				next = entry.Schedule.Next(now.Add(-time.Minute))
+				log.Printf("Scheduler: %s will %s at %s", server.ID(), entry.Action, next.Format(time.RFC1123))
+			}
+			if !next.IsZero() && !now.Before(next) {
+				due = entry
+				next = entry.Schedule.Next(now)
+			}
+			sc.next[key] = next
+			sc.mu.Unlock()
+		}
+
+		if due != nil {
+			runScheduledAction(s, config, server, due.Action, db)
+		}
+	}
+
+	// Servers missing after a failed listing keep their schedules
+	if err != nil {
+		return
+	}
+	sc.mu.Lock()
+	for key := range sc.next {
+		if !seen[key] {
+			delete(sc.next, key)
+		}
+	}
+	sc.mu.Unlock()
+}
+
+func runScheduledAction(s *discordgo.Session, config *util.JuiceBotConfig, server *gameServer, action string, db *sql.DB) {
+	var replicas int32
+	var content string
+	switch action {
+	case "start":
+		if server.Replicas > 0 {
+			return
+		}
+		if !scheduledStartFitsQuota(s, config, server, db) {
+			return
+		}
+		replicas = 1
+		content = fmt.Sprintf("🕒🟢 Scheduled start of **%s** (%s)", server.DisplayName, server.ID())
+	case "stop":
+		if server.Replicas == 0 {
+			return
+		}
+		replicas = 0
+		content = fmt.Sprintf("🕒🔴 Scheduled stop of **%s** (%s)", server.DisplayName, server.ID())
+	default:
+		return
+	}
+
+	err := scaleGameServer(server, replicas)
+	auditServerAction(s, server, "", "", "scheduled "+action, err, config, db)
+	if err != nil {
+		log.Printf("Scheduler failed to %s %s: %v", action, server.ID(), err)
+		announceToServerGuilds(s, config, server, &discordgo.MessageSend{
+			Content: fmt.Sprintf("❌ Scheduled %s of **%s** failed", action, server.DisplayName),
+		})
+		return
+	}
+
+	log.Printf("Scheduler ran %s for %s", action, server.ID())
+	recordServerUsage(db, server, action, "", "schedule")
+	announceToServerGuilds(s, config, server, &discordgo.MessageSend{Content: content})
+}
+
+// Check a scheduled start against the quota of every guild the server belongs to,
+// telling the first guild that would go over why the start was skipped
+func scheduledStartFitsQuota(s *discordgo.Session, config *util.JuiceBotConfig, server *gameServer, db *sql.DB) bool {
+	for _, guildID := range server.Guilds() {
+		reason, _, err := checkGuildQuota(config, guildID, server)
+		switch {
+		case err != nil:
+			log.Printf("Scheduler failed to check quota for %s in guild %s: %v", server.ID(), guildID, err)
+			auditServerAction(s, server, guildID, "", "scheduled start", err, config, db)
+			reason = "this guild's server quota couldn't be checked"
+		case reason != "":
+			log.Printf("Scheduler refused starting %s for guild %s: %s", server.ID(), guildID, reason)
+			auditServerResult(s, server, guildID, "", "scheduled start", auditDenied, fmt.Errorf("%w: %s", errQuotaExceeded, reason), config, db)
+		default:
+			continue
+		}
+
+		if channelID, ok := gamesChannelForGuild(config, guildID); ok {
+			_, err := s.ChannelMessageSend(channelID, fmt.Sprintf("🕒❌ Skipped scheduled start of **%s** (%s), %s", server.DisplayName, server.ID(), reason))
+			if err != nil {
+				log.Printf("Failed to announce skipped start of %s to guild %s: %v", server.ID(), guildID, err)
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Describe a schedule with the next time each clause fires
+func describeSchedule(entries []scheduleEntry, location *time.Location) string {
+	var lines []string
+	now := time.Now().In(location)
+	for _, entry := range entries {
+		line := fmt.Sprintf("`%s %s`", entry.Action, entry.Spec)
+		if next := entry.Schedule.Next(now); !next.IsZero() {
+			line += fmt.Sprintf(" - next <t:%d:F>", next.Unix())
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func handleServerSchedule(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
+	for _, opt := range options {
+		optionMap[opt.Name] = opt
+	}
+
+	serverOpt, ok := optionMap["server"]
+	if !ok {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Please specify a server ID to schedule (format: namespace/name)",
+			},
+		})
+		return
+	}
+	serverID := serverOpt.StringValue()
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "retrieve server schedule")
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+			},
+		})
+		return
+	}
+
+	location := scheduleLocation(config)
+
+	scheduleOpt, ok := optionMap["schedule"]
+	if !ok {
+		content := fmt.Sprintf("Server **%s** has no schedule", server.DisplayName)
+		if value, ok := server.Annotation(scheduleAnnotation); ok {
+			entries, err := parseServerSchedule(value)
+			if err != nil {
+				content = fmt.Sprintf("⚠️ Server **%s** has an invalid schedule `%s`: %v", server.DisplayName, value, err)
+			} else {
+				content = fmt.Sprintf("🕒 Schedule for **%s** (%s):\n%s", server.DisplayName, location, describeSchedule(entries, location))
+			}
+		}
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+			},
+		})
+		return
+	}
+
+	if !checkServerRoles(s, i, server, "schedule", config, db, ephemeralReply(s, i)) {
+		return
+	}
+
+	// Schedules are stored as annotations, so only cluster servers can have one
+	if !server.onKubernetes() {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("❌ Server **%s** is a %s, which can't be scheduled", server.DisplayName, strings.ToLower(server.Kind)),
+			},
+		})
+		return
+	}
+
+	value := strings.TrimSpace(scheduleOpt.StringValue())
+	if strings.EqualFold(value, "off") || strings.EqualFold(value, "none") {
+		err := annotateGameServer(server, scheduleAnnotation, nil)
+		auditServerAction(s, server, i.GuildID, i.Member.User.ID, "clear schedule", err, config, db)
+		if err != nil {
+			log.Printf("Failed to clear schedule on %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "❌ Unable to update schedule",
+				},
+			})
+			return
+		}
+		log.Printf("User %s in guild %s cleared the schedule of %s", i.Member.User.ID, i.GuildID, server.ID())
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("🕒 Cleared schedule for **%s**", server.DisplayName),
+			},
+		})
+		return
+	}
+
+	entries, err := parseServerSchedule(value)
+	if err != nil {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("❌ Invalid schedule: %v", err),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+
+	err = annotateGameServer(server, scheduleAnnotation, &value)
+	auditServerAction(s, server, i.GuildID, i.Member.User.ID, "set schedule "+value, err, config, db)
+	if err != nil {
+		log.Printf("Failed to set schedule on %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Unable to update schedule",
+			},
+		})
+		return
+	}
+
+	log.Printf("User %s in guild %s set the schedule of %s to %q", i.Member.User.ID, i.GuildID, server.ID(), value)
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("🕒 Updated schedule for **%s** (%s):\n%s", server.DisplayName, location, describeSchedule(entries, location)),
+		},
+	})
+}
@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// Maximum number of pods and events shown in a status embed
+const (
+	statusMaxPods   = 5
+	statusMaxEvents = 5
+)
+
+func handleServerStatus(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig) {
+	if len(options) == 0 {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Please specify a server ID to inspect (format: namespace/name)",
+			},
+		})
+		return
+	}
+
+	serverID := options[0].StringValue()
+
+	// Looking up pods and events can take a while, so defer the response
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		content := describeLookupError(i, serverID, err, "retrieve server status")
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	embed, err := server.backend.Status(server)
+	if err != nil {
+		log.Printf("Failed to build status for %s for user %s in guild %s: %v", server.ID(), i.Member.User.ID, i.GuildID, err)
+		content := "❌ Unable to retrieve server status"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+}
+
+// Collect pods and recent events for a server and render them as an embed
+func buildServerStatusEmbed(server *gameServer) (*discordgo.MessageEmbed, error) {
+	pods, err := listServerPods(server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+
+	state := server.State()
+	color := 0xe74c3c
+	switch state {
+	case "running":
+		color = 0x2ecc71
+	case "starting":
+		color = 0xf1c40f
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       server.DisplayName,
+		Description: fmt.Sprintf("%s `%s` - %s (%d/%d replicas)", server.Kind, server.ID(), state, server.ReadyReplicas, server.Replicas),
+		Color:       color,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	usage, err := listPodUsage(server, pods)
+	if err != nil {
+		// metrics-server is optional, the status is still useful without usage
+		log.Printf("Failed to get usage for %s: %v", server.ID(), err)
+	}
+
+	if len(pods) == 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Pods",
+			Value: "No pods running",
+		})
+	}
+
+	for idx, pod := range pods {
+		if idx == statusMaxPods {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:  "…",
+				Value: fmt.Sprintf("%d more pods not shown", len(pods)-statusMaxPods),
+			})
+			break
+		}
+		value := describePod(&pod)
+		if podUsage, ok := usage[pod.Name]; ok && pod.Status.Phase == corev1.PodRunning {
+			value = truncateField(value + "\n📊 " + describeUsage(podUsage))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  pod.Name,
+			Value: value,
+		})
+	}
+
+	events, err := listServerEvents(server, pods)
+	if err != nil {
+		// Events are nice to have, don't fail the whole status over them
+		log.Printf("Failed to list events for %s: %v", server.ID(), err)
+	} else if len(events) > 0 {
+		var lines []string
+		for _, event := range events {
+			lines = append(lines, describeEvent(&event))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Recent Events",
+			Value: truncateField(strings.Join(lines, "\n")),
+		})
+	}
+
+	return embed, nil
+}
+
+// List the pods matched by a server's selector, oldest first
+func listServerPods(server *gameServer) ([]corev1.Pod, error) {
+	if server.Selector == nil {
+		return nil, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(server.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := server.cluster().client.CoreV1().Pods(server.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := pods.Items
+	sort.Slice(items, func(a, b int) bool {
+		return items[a].CreationTimestamp.Before(&items[b].CreationTimestamp)
+	})
+	return items, nil
+}
+
+// List the most recent events for a server and its pods, newest first. Events are
+// fetched per object so busy namespaces don't send every event they have.
+func listServerEvents(server *gameServer, pods []corev1.Pod) ([]corev1.Event, error) {
+	names := []string{server.Name}
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+
+	var matched []corev1.Event
+	for _, name := range names {
+		events, err := server.cluster().client.CoreV1().Events(server.Namespace).List(context.TODO(), metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("involvedObject.name", name).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		matched = append(matched, events.Items...)
+	}
+
+	sort.Slice(matched, func(a, b int) bool {
+		return eventTime(&matched[a]).After(eventTime(&matched[b]))
+	})
+	if len(matched) > statusMaxEvents {
+		matched = matched[:statusMaxEvents]
+	}
+	return matched, nil
+}
+
+func describePod(pod *corev1.Pod) string {
+	var lines []string
+
+	age := "unknown"
+	if !pod.CreationTimestamp.IsZero() {
+		age = duration.HumanDuration(time.Since(pod.CreationTimestamp.Time))
+	}
+	node := pod.Spec.NodeName
+	if node == "" {
+		node = "unscheduled"
+	}
+	lines = append(lines, fmt.Sprintf("**Phase:** %s | **Node:** %s | **Age:** %s", pod.Status.Phase, node, age))
+
+	for _, container := range pod.Status.ContainerStatuses {
+		line := fmt.Sprintf("`%s` tag `%s` - %d restarts", container.Name, imageTag(container.Image), container.RestartCount)
+		if container.State.Waiting != nil && container.State.Waiting.Reason != "" {
+			line += fmt.Sprintf(", waiting: %s", container.State.Waiting.Reason)
+		}
+		if terminated := container.LastTerminationState.Terminated; terminated != nil {
+			line += fmt.Sprintf(", last terminated: %s (exit %d, %s ago)", terminated.Reason, terminated.ExitCode,
+				duration.HumanDuration(time.Since(terminated.FinishedAt.Time)))
+		}
+		lines = append(lines, line)
+	}
+
+	return truncateField(strings.Join(lines, "\n"))
+}
+
+func describeEvent(event *corev1.Event) string {
+	prefix := "ℹ️"
+	if event.Type == corev1.EventTypeWarning {
+		prefix = "⚠️"
+	}
+	age := duration.HumanDuration(time.Since(eventTime(event)))
+	return fmt.Sprintf("%s `%s` %s: %s (%s ago)", prefix, event.InvolvedObject.Name, event.Reason, event.Message, age)
+}
+
+// Events populate different timestamps depending on which API produced them
+func eventTime(event *corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// Extract the tag from an image reference, ignoring registry ports
+func imageTag(image string) string {
+	if idx := strings.Index(image, "@"); idx != -1 {
+		return image[idx+1:]
+	}
+	lastSlash := strings.LastIndex(image, "/")
+	if idx := strings.LastIndex(image, ":"); idx > lastSlash {
+		return image[idx+1:]
+	}
+	return "latest"
+}
+
+// Discord rejects embed field values longer than 1024 characters. Cut by rune
+// so the emoji starting event lines aren't split.
+func truncateField(value string) string {
+	return truncateLine(value, 1024)
+}
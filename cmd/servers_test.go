@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestServerCustomID(t *testing.T) {
+	short := "games/minecraft"
+	if got := serverCustomID("servers:panel:start:", short); got != "servers:panel:start:"+short {
+		t.Errorf("serverCustomID(%q) = %q, want the ID unchanged", short, got)
+	}
+
+	long := "cluster-" + strings.Repeat("n", 63) + "/" + strings.Repeat("s", 40)
+	got := serverCustomID("servers:panel:restart:", long)
+	if len(got) > customIDMaxLength {
+		t.Errorf("serverCustomID(%q) = %q, %d characters is over the limit", long, got, len(got))
+	}
+	if got != "servers:panel:restart:#"+hashServerID(long) {
+		t.Errorf("serverCustomID(%q) = %q, want the hashed ID", long, got)
+	}
+	if hashServerID(long) == hashServerID(long+"x") {
+		t.Errorf("hashServerID gave the same hash for different IDs")
+	}
+
+	// Plain IDs resolve without looking anything up
+	if got := resolveButtonServerID(nil, "guild", short); got != short {
+		t.Errorf("resolveButtonServerID(%q) = %q, want it unchanged", short, got)
+	}
+}
+
+func TestTruncateField(t *testing.T) {
+	short := "✅ Ready"
+	if got := truncateField(short); got != short {
+		t.Errorf("truncateField(%q) = %q, want it unchanged", short, got)
+	}
+
+	long := strings.Repeat("⚠️ BackOff\n", 200)
+	got := truncateField(long)
+	if !utf8.ValidString(got) {
+		t.Errorf("truncateField cut a rune in half: %q", got[len(got)-8:])
+	}
+	if count := utf8.RuneCountInString(got); count > 1024 {
+		t.Errorf("truncateField left %d characters, want at most 1024", count)
+	}
+}
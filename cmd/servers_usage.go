@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+)
+
+const (
+	usageDefaultDays = 7
+	// Rows shown per table in /servers usage
+	usageMaxRows = 15
+)
+
+// Periods offered by /servers usage
+var usageDayChoices = []*discordgo.ApplicationCommandOptionChoice{
+	{Name: "Last day", Value: 1},
+	{Name: "Last week", Value: 7},
+	{Name: "Last 30 days", Value: 30},
+	{Name: "Last 90 days", Value: 90},
+}
+
+// Record a start or stop for usage accounting. userID is empty when nobody asked for it.
+func recordServerUsage(db *sql.DB, server *gameServer, action string, userID string, source string) {
+	err := util.AddServerUsageEntry(db, util.ServerUsageEntry{
+		ServerID: server.ID(),
+		Action:   action,
+		UserID:   userID,
+		Source:   source,
+	})
+	if err != nil {
+		log.Printf("Failed to record %s of %s: %v", action, server.ID(), err)
+	}
+}
+
+// usageInterval is a stretch of time a server was running, credited to whoever started it
+type usageInterval struct {
+	ServerID string
+	UserID   string
+	Start    time.Time
+	End      time.Time
+}
+
+// Pair starts with stops to get running intervals, clipped to the period from since to now.
+// Servers that are still running count until now.
+func usageIntervals(events []util.ServerUsageEvent, since time.Time, now time.Time) []usageInterval {
+	running := map[string]usageInterval{}
+	var intervals []usageInterval
+
+	closeInterval := func(interval usageInterval, end time.Time) {
+		if interval.Start.Before(since) {
+			interval.Start = since
+		}
+		interval.End = end
+		if interval.End.After(interval.Start) {
+			intervals = append(intervals, interval)
+		}
+	}
+
+	for _, event := range events {
+		switch event.Action {
+		case "start":
+			// A second start while running, e.g. after a missed stop, keeps the original starter
+			if _, ok := running[event.ServerID]; !ok {
+				running[event.ServerID] = usageInterval{
+					ServerID: event.ServerID,
+					UserID:   event.UserID,
+					Start:    event.OccurredAt,
+				}
+			}
+		case "stop":
+			if interval, ok := running[event.ServerID]; ok {
+				closeInterval(interval, event.OccurredAt)
+				delete(running, event.ServerID)
+			}
+		}
+	}
+	for _, interval := range running {
+		closeInterval(interval, now)
+	}
+	return intervals
+}
+
+type usageTotal struct {
+	Key   string
+	Hours float64
+}
+
+// Sum interval hours by a key, largest first
+func sumUsage(intervals []usageInterval, key func(usageInterval) string) []usageTotal {
+	totals := map[string]float64{}
+	for _, interval := range intervals {
+		totals[key(interval)] += interval.End.Sub(interval.Start).Hours()
+	}
+
+	var sorted []usageTotal
+	for key, hours := range totals {
+		sorted = append(sorted, usageTotal{Key: key, Hours: hours})
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Hours > sorted[b].Hours
+	})
+	return sorted
+}
+
+func handleServerUsage(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, config *util.JuiceBotConfig, db *sql.DB) {
+	days := int64(usageDefaultDays)
+	for _, opt := range options {
+		if opt.Name == "days" {
+			days = opt.IntValue()
+		}
+	}
+
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	// Only report on servers this guild can see
+	servers, err := listGameServers(config, i.GuildID)
+	if err != nil {
+		log.Printf("Failed to list game servers for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
+	}
+	if err != nil && len(servers) == 0 {
+		content := "❌ Unable to retrieve game servers"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+	displayNames := map[string]string{}
+	var serverIDs []string
+	for _, server := range servers {
+		displayNames[server.ID()] = server.DisplayName
+		serverIDs = append(serverIDs, server.ID())
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -int(days))
+	events, err := util.GetServerUsage(db, serverIDs, since)
+	if err != nil {
+		log.Printf("Failed to get server usage for user %s in guild %s: %v", i.Member.User.ID, i.GuildID, err)
+		content := "❌ Unable to retrieve server usage"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	intervals := usageIntervals(events, since, now)
+
+	period := fmt.Sprintf("last %d days", days)
+	if days == 1 {
+		period = "last day"
+	}
+	content := fmt.Sprintf("**Server usage over the %s:**\n", period)
+	if len(intervals) == 0 {
+		content += "No servers ran in this period"
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return
+	}
+
+	byServer := sumUsage(intervals, func(interval usageInterval) string { return interval.ServerID })
+	for idx, total := range byServer {
+		if idx == usageMaxRows {
+			content += fmt.Sprintf("…and %d more\n", len(byServer)-usageMaxRows)
+			break
+		}
+		content += fmt.Sprintf("🖥️ **%s** (%s) - %.1fh\n", displayNames[total.Key], total.Key, total.Hours)
+	}
+
+	content += "\n**Started by:**\n"
+	byUser := sumUsage(intervals, func(interval usageInterval) string { return interval.UserID })
+	for idx, total := range byUser {
+		if idx == usageMaxRows {
+			content += fmt.Sprintf("…and %d more\n", len(byUser)-usageMaxRows)
+			break
+		}
+		starter := fmt.Sprintf("<@%s>", total.Key)
+		if total.Key == "" {
+			// Schedules, the idle reaper and scales made outside the bot
+			starter = "Automatic"
+		}
+		content += fmt.Sprintf("👤 %s - %.1fh\n", starter, total.Hours)
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Parse: []discordgo.AllowedMentionType{},
+		},
+	})
+}
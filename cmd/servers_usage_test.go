@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/clbx/juicebot/util"
+)
+
+func TestUsageIntervals(t *testing.T) {
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := since.Add(48 * time.Hour)
+	at := func(hours int) time.Time {
+		return since.Add(time.Duration(hours) * time.Hour)
+	}
+	event := func(serverID, action, userID string, hours int) util.ServerUsageEvent {
+		return util.ServerUsageEvent{ServerID: serverID, Action: action, UserID: userID, OccurredAt: at(hours)}
+	}
+
+	tests := []struct {
+		name   string
+		events []util.ServerUsageEvent
+		want   []usageInterval
+	}{
+		{
+			name:   "no events",
+			events: nil,
+			want:   nil,
+		},
+		{
+			name: "start and stop",
+			events: []util.ServerUsageEvent{
+				event("games/mc", "start", "alice", 1),
+				event("games/mc", "stop", "bob", 3),
+			},
+			want: []usageInterval{{ServerID: "games/mc", UserID: "alice", Start: at(1), End: at(3)}},
+		},
+		{
+			name: "started before the period",
+			events: []util.ServerUsageEvent{
+				event("games/mc", "start", "alice", -5),
+				event("games/mc", "stop", "", 2),
+			},
+			want: []usageInterval{{ServerID: "games/mc", UserID: "alice", Start: at(0), End: at(2)}},
+		},
+		{
+			name: "stopped before the period",
+			events: []util.ServerUsageEvent{
+				event("games/mc", "start", "alice", -5),
+				event("games/mc", "stop", "", -1),
+			},
+			want: nil,
+		},
+		{
+			name: "still running",
+			events: []util.ServerUsageEvent{
+				event("games/mc", "start", "alice", 40),
+			},
+			want: []usageInterval{{ServerID: "games/mc", UserID: "alice", Start: at(40), End: now}},
+		},
+		{
+			name: "running through the whole period",
+			events: []util.ServerUsageEvent{
+				event("games/mc", "start", "alice", -100),
+			},
+			want: []usageInterval{{ServerID: "games/mc", UserID: "alice", Start: at(0), End: now}},
+		},
+		{
+			name: "second start keeps the first starter",
+			events: []util.ServerUsageEvent{
+				event("games/mc", "start", "alice", 1),
+				event("games/mc", "start", "bob", 2),
+				event("games/mc", "stop", "", 4),
+			},
+			want: []usageInterval{{ServerID: "games/mc", UserID: "alice", Start: at(1), End: at(4)}},
+		},
+		{
+			name: "stop without a start",
+			events: []util.ServerUsageEvent{
+				event("games/mc", "stop", "alice", 1),
+				event("games/mc", "start", "bob", 2),
+				event("games/mc", "stop", "", 3),
+			},
+			want: []usageInterval{{ServerID: "games/mc", UserID: "bob", Start: at(2), End: at(3)}},
+		},
+		{
+			name: "zero length run",
+			events: []util.ServerUsageEvent{
+				event("games/mc", "start", "alice", 1),
+				event("games/mc", "stop", "", 1),
+			},
+			want: nil,
+		},
+		{
+			name: "interleaved servers",
+			events: []util.ServerUsageEvent{
+				event("games/mc", "start", "alice", 1),
+				event("games/tf2", "start", "bob", 2),
+				event("games/mc", "stop", "", 3),
+				event("games/mc", "start", "carol", 5),
+				event("games/tf2", "stop", "", 6),
+			},
+			want: []usageInterval{
+				{ServerID: "games/mc", UserID: "alice", Start: at(1), End: at(3)},
+				{ServerID: "games/tf2", UserID: "bob", Start: at(2), End: at(6)},
+				{ServerID: "games/mc", UserID: "carol", Start: at(5), End: now},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := usageIntervals(tt.events, since, now)
+		// Servers still running are closed in map order
+		sort.Slice(got, func(a, b int) bool {
+			return got[a].Start.Before(got[b].Start)
+		})
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: usageIntervals = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSumUsage(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	intervals := []usageInterval{
+		{ServerID: "games/mc", UserID: "alice", Start: start, End: start.Add(2 * time.Hour)},
+		{ServerID: "games/tf2", UserID: "bob", Start: start, End: start.Add(5 * time.Hour)},
+		{ServerID: "games/mc", UserID: "bob", Start: start, End: start.Add(90 * time.Minute)},
+	}
+
+	got := sumUsage(intervals, func(interval usageInterval) string { return interval.UserID })
+	want := []usageTotal{{Key: "bob", Hours: 6.5}, {Key: "alice", Hours: 2}}
+	if !slices.Equal(got, want) {
+		t.Errorf("sumUsage by user = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+)
+
+const startVoteDefaultWindow = 10 * time.Minute
+
+// startVote is an open vote to start a server, held separately in each guild that shares it
+type startVote struct {
+	GuildID     string
+	ServerID    string
+	DisplayName string
+	Required    int
+	Voters      []string
+	Deadline    time.Time
+	// The interaction whose response is the vote message
+	Interaction *discordgo.Interaction
+
+	timer *time.Timer
+}
+
+type voteResult int
+
+const (
+	voteClosed voteResult = iota
+	voteDuplicate
+	voteCounted
+	votePassed
+)
+
+type startVoteBoard struct {
+	mu    sync.Mutex
+	votes map[string]*startVote
+}
+
+var startVotes = &startVoteBoard{votes: map[string]*startVote{}}
+
+// Votes are keyed by guild and server, so guilds sharing a server vote on their own
+func startVoteKey(guildID string, serverID string) string {
+	return guildID + "|" + serverID
+}
+
+// Votes needed to start a server from the start-votes annotation, 0 if starting needs no vote
+func startVotesRequired(server *gameServer) int {
+	value, ok := server.Annotation("start-votes")
+	if !ok {
+		return 0
+	}
+	required, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Ignoring invalid start-votes %q on %s: %v", value, server.ID(), err)
+		return 0
+	}
+	return required
+}
+
+// How long a vote stays open, from the start-vote-window annotation
+func startVoteWindow(server *gameServer) time.Duration {
+	value, ok := server.Annotation("start-vote-window")
+	if !ok {
+		return startVoteDefaultWindow
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return startVoteDefaultWindow
+	}
+	// The vote message is an interaction response, which can't be edited once the token expires
+	if window > rolloutMaxTimeout {
+		return rolloutMaxTimeout
+	}
+	return window
+}
+
+// Add a member's vote, returning a snapshot of the vote afterwards. A passing vote is closed.
+func (b *startVoteBoard) cast(guildID string, serverID string, userID string) (startVote, voteResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := startVoteKey(guildID, serverID)
+	vote, ok := b.votes[key]
+	if !ok {
+		return startVote{}, voteClosed
+	}
+	if slices.Contains(vote.Voters, userID) {
+		return vote.snapshot(), voteDuplicate
+	}
+	vote.Voters = append(vote.Voters, userID)
+	if len(vote.Voters) < vote.Required {
+		return vote.snapshot(), voteCounted
+	}
+
+	vote.timer.Stop()
+	delete(b.votes, key)
+	return vote.snapshot(), votePassed
+}
+
+// Close a vote that ran out of time and say so on its message
+func (b *startVoteBoard) expire(s *discordgo.Session, vote *startVote) {
+	key := startVoteKey(vote.GuildID, vote.ServerID)
+	b.mu.Lock()
+	if b.votes[key] != vote {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.votes, key)
+	snapshot := vote.snapshot()
+	b.mu.Unlock()
+
+	log.Printf("Vote to start %s in guild %s expired with %d/%d votes", snapshot.ServerID, snapshot.GuildID, len(snapshot.Voters), snapshot.Required)
+	content := fmt.Sprintf("⌛ Vote to start **%s** expired with %d/%d votes", snapshot.DisplayName, len(snapshot.Voters), snapshot.Required)
+	s.InteractionResponseEdit(snapshot.Interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &[]discordgo.MessageComponent{},
+	})
+}
+
+func (v *startVote) snapshot() startVote {
+	snapshot := *v
+	snapshot.Voters = slices.Clone(v.Voters)
+	return snapshot
+}
+
+func describeStartVote(vote startVote) string {
+	var voters []string
+	for _, voter := range vote.Voters {
+		voters = append(voters, fmt.Sprintf("<@%s>", voter))
+	}
+	return fmt.Sprintf("🗳️ Vote to start **%s** (%s): %d/%d votes, closes <t:%d:R>\nVoted: %s",
+		vote.DisplayName, vote.ServerID, len(vote.Voters), vote.Required, vote.Deadline.Unix(), strings.Join(voters, ", "))
+}
+
+func startVoteComponents(serverID string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Vote to start",
+					Style:    discordgo.SuccessButton,
+					CustomID: serverCustomID("servers:vote:", serverID),
+				},
+			},
+		},
+	}
+}
+
+// Open a vote on a deferred /servers start response, or vote in the one already open for the server
+func openStartVote(s *discordgo.Session, i *discordgo.InteractionCreate, server *gameServer, required int, config *util.JuiceBotConfig, db *sql.DB) {
+	key := startVoteKey(i.GuildID, server.ID())
+	startVotes.mu.Lock()
+	_, exists := startVotes.votes[key]
+	if !exists {
+		vote := &startVote{
+			GuildID:     i.GuildID,
+			ServerID:    server.ID(),
+			DisplayName: server.DisplayName,
+			Required:    required,
+			Voters:      []string{i.Member.User.ID},
+			Deadline:    time.Now().Add(startVoteWindow(server)),
+			Interaction: i.Interaction,
+		}
+		vote.timer = time.AfterFunc(time.Until(vote.Deadline), func() {
+			startVotes.expire(s, vote)
+		})
+		startVotes.votes[key] = vote
+
+		content := describeStartVote(vote.snapshot())
+		startVotes.mu.Unlock()
+
+		components := startVoteComponents(server.ID())
+
+		log.Printf("User %s in guild %s opened a vote to start %s, %d votes required", i.Member.User.ID, i.GuildID, server.ID(), required)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Components: &components,
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Parse: []discordgo.AllowedMentionType{},
+			},
+		})
+		return
+	}
+	startVotes.mu.Unlock()
+
+	// Starting a server that already has a vote open counts as voting for it
+	vote, result := startVotes.cast(i.GuildID, server.ID(), i.Member.User.ID)
+	var content string
+	switch result {
+	case voteClosed:
+		content = fmt.Sprintf("⌛ The vote to start **%s** just closed, try again", server.DisplayName)
+	case voteDuplicate:
+		content = fmt.Sprintf("🗳️ You already voted to start **%s** (%d/%d votes)", server.DisplayName, len(vote.Voters), vote.Required)
+	case voteCounted:
+		log.Printf("User %s in guild %s voted to start %s (%d/%d)", i.Member.User.ID, i.GuildID, server.ID(), len(vote.Voters), vote.Required)
+		content = fmt.Sprintf("🗳️ A vote to start **%s** is already open, your vote was counted (%d/%d votes)", server.DisplayName, len(vote.Voters), vote.Required)
+		voteContent := describeStartVote(vote)
+		s.InteractionResponseEdit(vote.Interaction, &discordgo.WebhookEdit{
+			Content: &voteContent,
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Parse: []discordgo.AllowedMentionType{},
+			},
+		})
+	case votePassed:
+		log.Printf("Vote to start %s in guild %s passed with %d/%d votes", server.ID(), i.GuildID, len(vote.Voters), vote.Required)
+		voteContent := fmt.Sprintf("🗳️ Vote to start **%s** passed with %d/%d votes", server.DisplayName, len(vote.Voters), vote.Required)
+		s.InteractionResponseEdit(vote.Interaction, &discordgo.WebhookEdit{
+			Content:    &voteContent,
+			Components: &[]discordgo.MessageComponent{},
+		})
+		startServerAndFollow(s, i, server, "vote", config, db)
+		return
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+}
+
+func handleStartVoteButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverID string, config *util.JuiceBotConfig, db *sql.DB) {
+	server, err := findGameServer(config, serverID, i.GuildID, i.Member.User.ID)
+	if err != nil {
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: describeLookupError(i, serverID, err, "vote to start server"),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+
+	vote, result := startVotes.cast(i.GuildID, server.ID(), i.Member.User.ID)
+	switch result {
+	case voteClosed:
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "⌛ This vote has already closed",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	case voteDuplicate:
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "🗳️ You already voted",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	case voteCounted:
+		log.Printf("User %s in guild %s voted to start %s (%d/%d)", i.Member.User.ID, i.GuildID, server.ID(), len(vote.Voters), vote.Required)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    describeStartVote(vote),
+				Components: startVoteComponents(server.ID()),
+				AllowedMentions: &discordgo.MessageAllowedMentions{
+					Parse: []discordgo.AllowedMentionType{},
+				},
+			},
+		})
+	case votePassed:
+		log.Printf("Vote to start %s in guild %s passed with %d/%d votes", server.ID(), i.GuildID, len(vote.Voters), vote.Required)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    fmt.Sprintf("🗳️ Vote to start **%s** passed with %d/%d votes", server.DisplayName, len(vote.Voters), vote.Required),
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+
+		if server.Replicas > 0 {
+			content := fmt.Sprintf("🟢 Server **%s** is already running", server.DisplayName)
+			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content: &content,
+			})
+			return
+		}
+		// The vote message becomes the start progress message
+		startServerAndFollow(s, i, server, "vote", config, db)
+	}
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/clbx/juicebot/util"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// How long a scale made by the bot is remembered, so the watcher doesn't report it as external
+	expectedScaleTTL = 2 * time.Minute
+	// Clusters that can't be connected to at startup are retried with a wait doubling between these
+	watcherRetryMin = 30 * time.Second
+	watcherRetryMax = 10 * time.Minute
+)
+
+type expectedScale struct {
+	Replicas int32
+	At       time.Time
+}
+
+var (
+	expectedScalesMu sync.Mutex
+	expectedScales   = map[string]expectedScale{}
+)
+
+// Remember that the bot is about to scale a server
+func recordExpectedScale(serverID string, replicas int32) {
+	expectedScalesMu.Lock()
+	defer expectedScalesMu.Unlock()
+	expectedScales[serverID] = expectedScale{Replicas: replicas, At: time.Now()}
+}
+
+// Forget a scale the bot expected, for updates that failed
+func clearExpectedScale(serverID string) {
+	expectedScalesMu.Lock()
+	defer expectedScalesMu.Unlock()
+	delete(expectedScales, serverID)
+}
+
+// Check whether a replica change was made by the bot, forgetting it once seen
+func consumeExpectedScale(serverID string, replicas int32) bool {
+	expectedScalesMu.Lock()
+	defer expectedScalesMu.Unlock()
+
+	expected, ok := expectedScales[serverID]
+	if !ok {
+		return false
+	}
+	delete(expectedScales, serverID)
+	return expected.Replicas == replicas && time.Since(expected.At) < expectedScaleTTL
+}
+
+// Start shared informers on labelled Deployments and StatefulSets in every cluster and announce state transitions
+func StartServerWatcher(s *discordgo.Session, config *util.JuiceBotConfig, db *sql.DB) {
+	if config.Servers.DisableKubernetes {
+		return
+	}
+
+	// The watcher runs for the life of the bot
+	stop := make(chan struct{})
+	for _, cluster := range kubernetesClusters(config) {
+		if err := cluster.connect(); err != nil {
+			log.Printf("Server watcher failed to initialize Kubernetes client for cluster %s, retrying in %s: %v", cluster.displayName(), watcherRetryMin, err)
+			go retryWatchCluster(s, config, cluster, stop, db)
+			continue
+		}
+		watchCluster(s, config, cluster, stop, db)
+	}
+}
+
+// Keep trying to connect to a cluster that failed at startup, then watch it
+func retryWatchCluster(s *discordgo.Session, config *util.JuiceBotConfig, cluster *kubernetesBackend, stop chan struct{}, db *sql.DB) {
+	wait := watcherRetryMin
+	for {
+		time.Sleep(wait)
+		err := cluster.connect()
+		if err == nil {
+			log.Printf("Server watcher connected to cluster %s", cluster.displayName())
+			watchCluster(s, config, cluster, stop, db)
+			return
+		}
+		wait = min(wait*2, watcherRetryMax)
+		log.Printf("Server watcher failed to initialize Kubernetes client for cluster %s, retrying in %s: %v", cluster.displayName(), wait, err)
+	}
+}
+
+func watchCluster(s *discordgo.Session, config *util.JuiceBotConfig, cluster *kubernetesBackend, stop chan struct{}, db *sql.DB) {
+	// Shared informer factories are scoped to a single namespace, so run one per namespace
+	for _, namespace := range allNamespaces(config) {
+		factory := informers.NewSharedInformerFactoryWithOptions(cluster.client, 10*time.Minute,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = config.Servers.LabelSelector
+			}),
+		)
+
+		factory.Apps().V1().Deployments().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldDeployment, ok := oldObj.(*appsv1.Deployment)
+				if !ok {
+					return
+				}
+				newDeployment, ok := newObj.(*appsv1.Deployment)
+				if !ok {
+					return
+				}
+				announceTransition(s, config, gameServerFromDeployment(config, cluster, oldDeployment), gameServerFromDeployment(config, cluster, newDeployment), db)
+			},
+		})
+
+		factory.Apps().V1().StatefulSets().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldStatefulSet, ok := oldObj.(*appsv1.StatefulSet)
+				if !ok {
+					return
+				}
+				newStatefulSet, ok := newObj.(*appsv1.StatefulSet)
+				if !ok {
+					return
+				}
+				announceTransition(s, config, gameServerFromStatefulSet(config, cluster, oldStatefulSet), gameServerFromStatefulSet(config, cluster, newStatefulSet), db)
+			},
+		})
+
+		factory.Start(stop)
+		go func(namespace string) {
+			for informerType, synced := range factory.WaitForCacheSync(stop) {
+				if !synced {
+					log.Printf("Server watcher failed to sync informer for %v in %s", informerType, cluster.describeNamespace(namespace))
+				}
+			}
+			log.Printf("Server watcher started for namespace %s", cluster.describeNamespace(namespace))
+		}(namespace)
+	}
+}
+
+// Post a message for a state change between two observations of the same server
+func announceTransition(s *discordgo.Session, config *util.JuiceBotConfig, before *gameServer, after *gameServer, db *sql.DB) {
+	// Starts and stops made through the bot are already announced and recorded by whoever made them
+	external := before.Replicas != after.Replicas && !consumeExpectedScale(after.ID(), after.Replicas)
+	if external && before.Replicas == 0 {
+		recordServerUsage(db, after, "start", "", "external")
+	}
+	if external && after.Replicas == 0 {
+		recordServerUsage(db, after, "stop", "", "external")
+	}
+
+	content := describeTransition(before, after, external)
+	if content == "" {
+		return
+	}
+
+	log.Printf("Server watcher: %s %s -> %s (%d/%d -> %d/%d replicas)", after.ID(),
+		before.State(), after.State(), before.ReadyReplicas, before.Replicas, after.ReadyReplicas, after.Replicas)
+	announceToServerGuilds(s, config, after, &discordgo.MessageSend{Content: content})
+}
+
+func describeTransition(before *gameServer, after *gameServer, external bool) string {
+	if before.Replicas != after.Replicas {
+		if !external {
+			return ""
+		}
+		return fmt.Sprintf("⚙️ **%s** (%s) was scaled from %d to %d outside of JuiceBot", after.DisplayName, after.ID(), before.Replicas, after.Replicas)
+	}
+
+	if before.ReadyReplicas == 0 && after.ReadyReplicas > 0 {
+		return fmt.Sprintf("✅ **%s** (%s) is ready", after.DisplayName, after.ID())
+	}
+
+	if before.ReadyReplicas > 0 && after.ReadyReplicas == 0 && after.Replicas > 0 {
+		return fmt.Sprintf("💥 **%s** (%s) is no longer ready and may be crashing", after.DisplayName, after.ID())
+	}
+
+	return ""
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -100,12 +101,19 @@ var (
 			cmd.DogAction(s, i, &config)
 		},
 		"servers": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-			cmd.ServersAction(s, i, &config)
+			cmd.ServersAction(s, i, &config, db)
 		},
 		"namehistory": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 			cmd.NameHistoryAction(s, i, &config, db)
 		},
 	}
+
+	// Button handlers, keyed by the custom ID prefix before the first ":"
+	componentHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+		"servers": func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+			cmd.ServersComponentAction(s, i, &config, db)
+		},
+	}
 )
 
 func ping(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -119,8 +127,16 @@ func ping(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 func init() {
 	s.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
-			h(s, i)
+		switch i.Type {
+		case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+			if h, ok := commandHandlers[i.ApplicationCommandData().Name]; ok {
+				h(s, i)
+			}
+		case discordgo.InteractionMessageComponent:
+			prefix, _, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+			if h, ok := componentHandlers[prefix]; ok {
+				h(s, i)
+			}
 		}
 	})
 
@@ -155,6 +171,12 @@ func main() {
 		log.Fatalf("Cannot open the session: %v", err)
 	}
 
+	cmd.CheckKubernetesClusters(&config)
+	cmd.TrackLocalProcessUsage(db)
+	cmd.StartIdleReaper(s, &config, db)
+	cmd.StartScheduler(s, &config, db)
+	cmd.StartServerWatcher(s, &config, db)
+
 	log.Println("Adding commands...")
 	log.Printf("%d Commands found\n", len(commands))
 	registeredCommands := make([]*discordgo.ApplicationCommand, len(commands))
@@ -186,5 +208,7 @@ func main() {
 
 	}
 
+	cmd.StopLocalProcesses()
+
 	log.Println("Gracefully shutting down.")
 }
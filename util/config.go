@@ -26,6 +26,68 @@ type JuiceBotConfig struct {
 			ChannelID string `yaml:"channelid"`
 		} `yaml:"channels"`
 	} `yaml:"games"`
+	Servers struct {
+		// Namespaces searched for game servers, and the per-guild overrides
+		Namespaces      []string `yaml:"namespaces"`
+		GuildNamespaces []struct {
+			GuildID    string   `yaml:"guildid"`
+			Namespaces []string `yaml:"namespaces"`
+		} `yaml:"guildNamespaces"`
+		// Extra scalable resources to manage alongside Deployments and StatefulSets
+		CustomResources []struct {
+			Group    string `yaml:"group"`
+			Version  string `yaml:"version"`
+			Resource string `yaml:"resource"`
+		} `yaml:"customResources"`
+		LabelSelector     string   `yaml:"labelSelector"`
+		AnnotationPrefix  string   `yaml:"annotationPrefix"`
+		DisplayNameLabel  string   `yaml:"displayNameLabel"`
+		LogRedactPatterns []string `yaml:"logRedactPatterns"`
+		NodeAddress       string   `yaml:"nodeAddress"`
+		ScheduleTimezone  string   `yaml:"scheduleTimezone"`
+		// Channels server actions are mirrored to, per guild
+		AuditChannels []struct {
+			GuildID   string `yaml:"guildid"`
+			ChannelID string `yaml:"channelid"`
+		} `yaml:"auditChannels"`
+		// Skip the cluster entirely, for bots that only manage processes
+		DisableKubernetes bool `yaml:"disableKubernetes"`
+		// Clusters game servers run in, by default ~/.kube/config and then the in-cluster config.
+		// Servers in a named cluster have IDs like <cluster>/<namespace>/<name>.
+		Clusters []struct {
+			Name string `yaml:"name"`
+			// Kubeconfig path and context, the current context if empty
+			Kubeconfig string `yaml:"kubeconfig"`
+			Context    string `yaml:"context"`
+			InCluster  bool   `yaml:"inCluster"`
+			// Guilds that use this cluster, every guild if empty
+			Guilds []string `yaml:"guilds"`
+		} `yaml:"clusters"`
+		// Game servers run as processes next to the bot, listed as local/<name>
+		Processes []struct {
+			Name        string   `yaml:"name"`
+			DisplayName string   `yaml:"displayName"`
+			Command     []string `yaml:"command"`
+			WorkingDir  string   `yaml:"workingDir"`
+			// Signal sent on stop before the process is killed, SIGTERM by default
+			StopSignal string   `yaml:"stopSignal"`
+			Guilds     []string `yaml:"guilds"`
+			Connect    string   `yaml:"connect"`
+		} `yaml:"processes"`
+		// Role IDs allowed to manage servers that have no juicebot-roles annotation
+		GuildRoles []struct {
+			GuildID string   `yaml:"guildid"`
+			Roles   []string `yaml:"roles"`
+		} `yaml:"guildRoles"`
+		// Limits on what each guild may run at once. CPU and memory budgets are
+		// Kubernetes quantities compared against the summed requests of running servers.
+		GuildQuotas []struct {
+			GuildID    string `yaml:"guildid"`
+			MaxRunning int    `yaml:"maxRunning"`
+			CPU        string `yaml:"cpu"`
+			Memory     string `yaml:"memory"`
+		} `yaml:"guildQuotas"`
+	} `yaml:"servers"`
 }
 
 func NewJuiceBotConfig(configPath string) *JuiceBotConfig {
@@ -40,5 +102,19 @@ func NewJuiceBotConfig(configPath string) *JuiceBotConfig {
 		panic(err)
 	}
 
+	// Game server discovery defaults
+	if len(config.Servers.Namespaces) == 0 {
+		config.Servers.Namespaces = []string{"games"}
+	}
+	if config.Servers.LabelSelector == "" {
+		config.Servers.LabelSelector = "juicecloud.org/juicebot-game-server=true"
+	}
+	if config.Servers.AnnotationPrefix == "" {
+		config.Servers.AnnotationPrefix = "juicecloud.org/juicebot-"
+	}
+	if config.Servers.DisplayNameLabel == "" {
+		config.Servers.DisplayNameLabel = "app.kubernetes.io/name"
+	}
+
 	return &config
 }
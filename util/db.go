@@ -2,7 +2,9 @@ package util
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 )
 
 func InitDB(db *sql.DB) error {
@@ -29,6 +31,45 @@ func InitDB(db *sql.DB) error {
 			changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 		);`
 
+	createServerUsageTableQuery := `
+		CREATE TABLE IF NOT EXISTS server_usage (
+			id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			server_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			user_id TEXT NOT NULL DEFAULT '',
+			source TEXT NOT NULL,
+			occurred_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`
+
+	createServerUsageIndexQuery := `
+		CREATE INDEX IF NOT EXISTS server_usage_server_occurred_at ON server_usage (server_id, occurred_at);`
+
+	createServerAuditTableQuery := `
+		CREATE TABLE IF NOT EXISTS server_audit (
+			id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			guild_id TEXT NOT NULL,
+			user_id TEXT NOT NULL DEFAULT '',
+			server_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			result TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`
+
+	createServerBackupsTableQuery := `
+		CREATE TABLE IF NOT EXISTS server_backups (
+			id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			guild_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			server_id TEXT NOT NULL,
+			method TEXT NOT NULL,
+			resources TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMPTZ
+		);`
+
 	_, err := db.Exec(createGamesTableQuery)
 	if err != nil {
 		return fmt.Errorf("failed to create games table. %w", err)
@@ -44,6 +85,26 @@ func InitDB(db *sql.DB) error {
 		return fmt.Errorf("Failed to create name history table. %w", err)
 	}
 
+	_, err = db.Exec(createServerUsageTableQuery)
+	if err != nil {
+		return fmt.Errorf("Failed to create server usage table. %w", err)
+	}
+
+	_, err = db.Exec(createServerUsageIndexQuery)
+	if err != nil {
+		return fmt.Errorf("Failed to create server usage index. %w", err)
+	}
+
+	_, err = db.Exec(createServerAuditTableQuery)
+	if err != nil {
+		return fmt.Errorf("Failed to create server audit table. %w", err)
+	}
+
+	_, err = db.Exec(createServerBackupsTableQuery)
+	if err != nil {
+		return fmt.Errorf("Failed to create server backups table. %w", err)
+	}
+
 	return nil
 
 }
@@ -91,3 +152,186 @@ func GetNameHistory(db *sql.DB, guildID string, userID string) ([]NameHistoryEnt
 
 	return history, nil
 }
+
+type ServerUsageEntry struct {
+	ServerID string
+	Action   string
+	UserID   string
+	Source   string
+}
+
+func AddServerUsageEntry(db *sql.DB, entry ServerUsageEntry) error {
+	query := `INSERT INTO server_usage (server_id, action, user_id, source) VALUES ($1, $2, $3, $4)`
+	_, err := db.Exec(query, entry.ServerID, entry.Action, entry.UserID, entry.Source)
+	if err != nil {
+		return fmt.Errorf("Failed to write server usage to the DB. %w", err)
+	}
+	return nil
+}
+
+type ServerUsageEvent struct {
+	ServerID   string
+	Action     string
+	UserID     string
+	Source     string
+	OccurredAt time.Time
+}
+
+// GetServerUsage returns the events of the given servers since the given time, plus the last event before it
+// for each server so servers that were already running at that point are counted, oldest first
+func GetServerUsage(db *sql.DB, serverIDs []string, since time.Time) ([]ServerUsageEvent, error) {
+	query := `
+		SELECT server_id, action, user_id, source, occurred_at FROM server_usage
+		WHERE server_id = ANY($1) AND (occurred_at >= $2 OR id IN (
+			SELECT DISTINCT ON (server_id) id FROM server_usage
+			WHERE server_id = ANY($1) AND occurred_at < $2
+			ORDER BY server_id, occurred_at DESC
+		))
+		ORDER BY occurred_at`
+	rows, err := db.Query(query, serverIDs, since)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query server usage. %w", err)
+	}
+	defer rows.Close()
+
+	var events []ServerUsageEvent
+	for rows.Next() {
+		var event ServerUsageEvent
+		err := rows.Scan(&event.ServerID, &event.Action, &event.UserID, &event.Source, &event.OccurredAt)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scan server usage row. %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// GetServerStartedAt returns when a server was last started, reporting false if its latest event isn't a start
+func GetServerStartedAt(db *sql.DB, serverID string) (time.Time, bool, error) {
+	query := `
+		SELECT action, occurred_at FROM server_usage
+		WHERE server_id = $1
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT 1`
+	var action string
+	var occurredAt time.Time
+	err := db.QueryRow(query, serverID).Scan(&action, &occurredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("Failed to query server start. %w", err)
+	}
+	return occurredAt, action == "start", nil
+}
+
+type ServerAuditEntry struct {
+	GuildID   string
+	UserID    string
+	ServerID  string
+	Action    string
+	Result    string
+	Error     string
+	CreatedAt time.Time
+}
+
+func AddServerAuditEntry(db *sql.DB, entry ServerAuditEntry) error {
+	query := `INSERT INTO server_audit (guild_id, user_id, server_id, action, result, error) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := db.Exec(query, entry.GuildID, entry.UserID, entry.ServerID, entry.Action, entry.Result, entry.Error)
+	if err != nil {
+		return fmt.Errorf("Failed to write server audit entry to the DB. %w", err)
+	}
+	return nil
+}
+
+// GetServerAudit returns a guild's audit entries newest first, optionally for a single server
+func GetServerAudit(db *sql.DB, guildID string, serverID string, limit int, offset int) ([]ServerAuditEntry, error) {
+	query := `
+		SELECT guild_id, user_id, server_id, action, result, error, created_at FROM server_audit
+		WHERE guild_id = $1 AND ($2 = '' OR server_id = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`
+	rows, err := db.Query(query, guildID, serverID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query server audit. %w", err)
+	}
+	defer rows.Close()
+
+	var entries []ServerAuditEntry
+	for rows.Next() {
+		var entry ServerAuditEntry
+		err := rows.Scan(&entry.GuildID, &entry.UserID, &entry.ServerID, &entry.Action, &entry.Result, &entry.Error, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scan server audit row. %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+type ServerBackup struct {
+	ID       int64
+	GuildID  string
+	UserID   string
+	ServerID string
+	// "job" or "snapshot"
+	Method string
+	// Names of the Job or VolumeSnapshots, comma separated
+	Resources  string
+	Status     string
+	Error      string
+	StartedAt  time.Time
+	FinishedAt sql.NullTime
+}
+
+// AddServerBackup records a backup that has just been started and returns its ID
+func AddServerBackup(db *sql.DB, backup ServerBackup) (int64, error) {
+	query := `
+		INSERT INTO server_backups (guild_id, user_id, server_id, method, resources, status)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	var id int64
+	err := db.QueryRow(query, backup.GuildID, backup.UserID, backup.ServerID, backup.Method, backup.Resources, backup.Status).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to write server backup to the DB. %w", err)
+	}
+	return id, nil
+}
+
+// FinishServerBackup records the outcome of a backup
+func FinishServerBackup(db *sql.DB, id int64, status string, backupErr string) error {
+	query := `UPDATE server_backups SET status = $2, error = $3, finished_at = CURRENT_TIMESTAMP WHERE id = $1`
+	_, err := db.Exec(query, id, status, backupErr)
+	if err != nil {
+		return fmt.Errorf("Failed to update server backup in the DB. %w", err)
+	}
+	return nil
+}
+
+// GetServerBackups returns a guild's backups newest first, optionally for a single server
+func GetServerBackups(db *sql.DB, guildID string, serverID string, limit int) ([]ServerBackup, error) {
+	query := `
+		SELECT id, guild_id, user_id, server_id, method, resources, status, error, started_at, finished_at FROM server_backups
+		WHERE guild_id = $1 AND ($2 = '' OR server_id = $2)
+		ORDER BY started_at DESC, id DESC
+		LIMIT $3`
+	rows, err := db.Query(query, guildID, serverID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query server backups. %w", err)
+	}
+	defer rows.Close()
+
+	var backups []ServerBackup
+	for rows.Next() {
+		var backup ServerBackup
+		err := rows.Scan(&backup.ID, &backup.GuildID, &backup.UserID, &backup.ServerID, &backup.Method, &backup.Resources,
+			&backup.Status, &backup.Error, &backup.StartedAt, &backup.FinishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scan server backup row. %w", err)
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, rows.Err()
+}